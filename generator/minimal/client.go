@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"path"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -14,15 +15,36 @@ import (
 )
 
 const apiTemplate = `
-import {createTwirpRequest, throwTwirpError, Fetch} from './twirp';
+import {createTwirpRequest, throwTwirpError, Fetch, Interceptor, TwirpContext, chainInterceptors, bytesToBase64, base64ToBytes, encodeFloat, decodeFloat{{if $.WantsProtobuf}}, createTwirpProtoRequest, Writer, Reader, wireLengthDelimited, UnknownField{{end}}{{if $.WantsStreaming}}, readTwirpStream{{end}}} from './twirp';
+{{- range .Imports}}
+import { {{range $i, $s := .Symbols}}{{if $i}}, {{end}}{{$s}}{{end}} } from '{{.Path}}';
+{{- end}}
 
 {{- range .Enums}}
+{{- $enumName := .Name}}
 
 export enum {{.Name}} {
 {{- range .Values}}
-	{{.}} = "{{.}}",
+	{{.Name}} = "{{.Name}}",
 {{- end}}
 }
+{{- if $.WantsProtobuf}}
+
+const {{.Name}}Number: { [key in {{.Name}}]: number } = {
+{{- range .Values}}
+	[{{$enumName}}.{{.Name}}]: {{.Number}},
+{{- end}}
+};
+
+const {{.Name}}FromNumber = (n: number): {{.Name}} => {
+	switch (n) {
+	{{- range .Values}}
+	case {{.Number}}: return {{$enumName}}.{{.Name}};
+	{{- end}}
+	default: return n as unknown as {{.Name}};
+	}
+};
+{{- end}}
 {{- end -}}
 
 {{- range .Models -}}
@@ -33,10 +55,26 @@ export interface {{.Name}} {
 	[key: string]: {{.MapValueType}};
 {{- else -}}
 {{- range .Fields}}
+{{- if eq .OneofField ""}}
     {{.Name}}?: {{.Type}};
 {{- end}}
 {{- end}}
+{{- range .Oneofs}}
+    {{.FieldName}}?: {{.Name}};
+{{- end}}
+{{- if $.WantsProtobuf}}
+    // unrecognized fields preserved from a decode, re-emitted verbatim by a later encode.
+    __unknownFields?: UnknownField[];
+{{- end}}
+{{- end}}
 }
+{{- range .Oneofs}}
+
+export type {{.Name}} =
+{{- range $i, $m := .Members}}
+	| { case: "{{$m.OneofCase}}"; value: {{$m.Type}} }
+{{- end}};
+{{- end}}
 
 interface {{.Name}}JSON {
 {{- if .IsMap}}
@@ -83,12 +121,62 @@ const JSONTo{{.Name}} = (m: {{.Name}}JSON): {{.Name}} => {
 {{- else}}
     return {
         {{- range .Fields}}
+        {{- if eq .OneofField ""}}
         {{.Name}}: {{parse . $Model}},
         {{- end}}
+        {{- end}}
+        {{- range .Oneofs}}
+        {{.FieldName}}: {{parseOneof . $Model}},
+        {{- end}}
 	};
 {{- end}}
 };
 {{- end -}}
+
+{{- if $.WantsProtobuf}}
+{{- if .CanMarshal}}
+
+const encode{{.Name}} = (m: {{.Name}}): Uint8Array => {
+	const w = new Writer();
+	{{- range .Fields}}
+	{{protoEncode .}}
+	{{- end}}
+{{- if not .IsMap}}
+	if (m.__unknownFields) {
+		for (const u of m.__unknownFields) {
+			w.tag(u.fieldNumber, u.wireType).raw(u.data);
+		}
+	}
+{{- end}}
+	return w.finish();
+};
+{{- end}}
+
+{{- if .CanUnmarshal}}
+
+const decode{{.Name}} = (bytes: Uint8Array): {{.Name}} => {
+	const r = new Reader(bytes);
+	const m: {{.Name}} = {};
+	while (r.pos < r.len) {
+		const [fieldNumber, wireType] = r.tag();
+		switch (fieldNumber) {
+		{{- range .Fields}}
+		{{protoDecodeCase .}}
+		{{- end}}
+		default:
+{{- if not .IsMap}}
+			m.__unknownFields = m.__unknownFields || [];
+			m.__unknownFields.push({ fieldNumber, wireType, data: r.captureField(wireType) });
+{{- else}}
+			r.skip(wireType);
+{{- end}}
+			break;
+		}
+	}
+	return m;
+};
+{{- end}}
+{{- end -}}
 {{end -}}
 {{end -}}
 {{end -}}
@@ -99,41 +187,118 @@ const JSONTo{{.Name}} = (m: {{.Name}}JSON): {{.Name}} => {
 
 export interface {{.Name}} {
 {{- range .Methods}}
+{{- if .ServerStreaming}}
+    {{.Name}}: ({{.InputArg}}: {{.InputType}}, signal?: AbortSignal) => AsyncIterable<{{.OutputType}}>;
+{{- else}}
     {{.Name}}: ({{.InputArg}}: {{.InputType}}) => Promise<{{.OutputType}}>;
 {{- end}}
+{{- end}}
 }
 
 export class {{.Name}}Client implements {{.Name}} {
     private hostname: string;
-    private fetch: Fetch;
+    private callFetch: (ctx: TwirpContext, req: Request) => Promise<Response>;
     private writeCamelCase: boolean;
 	private pathPrefix = "{{$twirpPrefix}}/{{.Package}}.{{.Name}}/";
 	private optionsOverride: object;
+{{- if eq $.Transport "both"}}
+	private contentType: "application/json" | "application/protobuf";
+{{- end}}
 
-    constructor(hostname: string, fetch: Fetch, writeCamelCase = false, optionsOverride: any = {}) {
+    constructor(hostname: string, fetch: Fetch, interceptors: Interceptor[] = [], writeCamelCase = false, optionsOverride: any = {}{{if eq $.Transport "both"}}, contentType: "application/json" | "application/protobuf" = "application/json"{{end}}) {
         this.hostname = hostname;
-        this.fetch = fetch;
+        this.callFetch = chainInterceptors(fetch, interceptors);
 		this.writeCamelCase = writeCamelCase;
 		this.optionsOverride = optionsOverride;
+{{- if eq $.Transport "both"}}
+		this.contentType = contentType;
+{{- end}}
     }
 
+{{- $svcPackage := .Package}}
+{{- $svcName := .Name}}
 {{- range .Methods}}
 
+{{- if .ServerStreaming}}
+
+    {{.Name}}({{.InputArg}}: {{.InputType}}, signal?: AbortSignal): AsyncIterable<{{.OutputType}}> {
+        const url = this.hostname + this.pathPrefix + "{{.Path}}";
+        const ctx: TwirpContext = {packageName: "{{$svcPackage}}", serviceName: "{{$svcName}}", methodName: "{{.Path}}"};
+{{- if eq $.Transport "json"}}
+        let body: {{.InputType}} | {{.InputType}}JSON = {{.InputArg}};
+        if (!this.writeCamelCase) {
+            body = {{.InputType}}ToJSON({{.InputArg}});
+        }
+        const req = createTwirpRequest(url, body, this.optionsOverride);
+        return readTwirpStream(this.callFetch(ctx, signal ? new Request(req, {signal}) : req), (payload) => JSONTo{{.OutputType}}(JSON.parse(new TextDecoder().decode(payload))));
+{{- else if eq $.Transport "protobuf"}}
+        const req = createTwirpProtoRequest(url, encode{{.InputType}}({{.InputArg}}), this.optionsOverride);
+        return readTwirpStream(this.callFetch(ctx, signal ? new Request(req, {signal}) : req), decode{{.OutputType}});
+{{- else}}
+        if (this.contentType === "application/protobuf") {
+            const req = createTwirpProtoRequest(url, encode{{.InputType}}({{.InputArg}}), this.optionsOverride);
+            return readTwirpStream(this.callFetch(ctx, signal ? new Request(req, {signal}) : req), decode{{.OutputType}});
+        }
+
+        let body: {{.InputType}} | {{.InputType}}JSON = {{.InputArg}};
+        if (!this.writeCamelCase) {
+            body = {{.InputType}}ToJSON({{.InputArg}});
+        }
+        const req = createTwirpRequest(url, body, this.optionsOverride);
+        return readTwirpStream(this.callFetch(ctx, signal ? new Request(req, {signal}) : req), (payload) => JSONTo{{.OutputType}}(JSON.parse(new TextDecoder().decode(payload))));
+{{- end}}
+    }
+{{- else}}
+
     {{.Name}}({{.InputArg}}: {{.InputType}}): Promise<{{.OutputType}}> {
         const url = this.hostname + this.pathPrefix + "{{.Path}}";
+        const ctx: TwirpContext = {packageName: "{{$svcPackage}}", serviceName: "{{$svcName}}", methodName: "{{.Path}}"};
+{{- if eq $.Transport "json"}}
+        let body: {{.InputType}} | {{.InputType}}JSON = {{.InputArg}};
+        if (!this.writeCamelCase) {
+            body = {{.InputType}}ToJSON({{.InputArg}});
+        }
+        return this.callFetch(ctx, createTwirpRequest(url, body, this.optionsOverride)).then((resp) => {
+            if (!resp.ok) {
+                return throwTwirpError(resp);
+            }
+
+            return resp.json().then(JSONTo{{.OutputType}});
+        });
+{{- else if eq $.Transport "protobuf"}}
+        return this.callFetch(ctx, createTwirpProtoRequest(url, encode{{.InputType}}({{.InputArg}}), this.optionsOverride)).then((resp) => {
+            if (!resp.ok) {
+                return throwTwirpError(resp);
+            }
+
+            return resp.arrayBuffer().then((buf) => decode{{.OutputType}}(new Uint8Array(buf)));
+        });
+{{- else}}
+        if (this.contentType === "application/protobuf") {
+            return this.callFetch(ctx, createTwirpProtoRequest(url, encode{{.InputType}}({{.InputArg}}), this.optionsOverride)).then((resp) => {
+                if (!resp.ok) {
+                    return throwTwirpError(resp);
+                }
+
+                return resp.arrayBuffer().then((buf) => decode{{.OutputType}}(new Uint8Array(buf)));
+            });
+        }
+
         let body: {{.InputType}} | {{.InputType}}JSON = {{.InputArg}};
         if (!this.writeCamelCase) {
             body = {{.InputType}}ToJSON({{.InputArg}});
         }
-        return this.fetch(createTwirpRequest(url, body, this.optionsOverride)).then((resp) => {
+        return this.callFetch(ctx, createTwirpRequest(url, body, this.optionsOverride)).then((resp) => {
             if (!resp.ok) {
                 return throwTwirpError(resp);
             }
 
             return resp.json().then(JSONTo{{.OutputType}});
         });
+{{- end}}
     }
 {{- end}}
+{{- end}}
 }
 {{- end}}
 `
@@ -142,6 +307,7 @@ type Model struct {
 	Name                  string
 	Primitive             bool
 	Fields                []ModelField
+	Oneofs                []*OneofGroup
 	CanMarshal            bool
 	CanUnmarshal          bool
 	IsMap                 bool
@@ -149,6 +315,15 @@ type Model struct {
 	MapValueTypePrimitive bool
 }
 
+// OneofGroup describes one `oneof` declaration with more than one member,
+// rendered as a TS discriminated union named Name and exposed on the
+// containing interface as an optional field named FieldName.
+type OneofGroup struct {
+	Name      string
+	FieldName string
+	Members   []ModelField
+}
+
 type ModelField struct {
 	Name                  string
 	Type                  string
@@ -158,6 +333,27 @@ type ModelField struct {
 	IsRepeated            bool
 	IsMap                 bool
 	MapValueTypePrimitive bool
+	Kind                  FieldKind
+	Number                int32
+	WireType              int32
+	ZigZag                bool
+	Signed                bool
+	Packed                bool
+	// ProtoTypeName is the fully-qualified proto name (FieldDescriptorProto.GetTypeName)
+	// of a message/enum field's type, used as the modelLookup key. Empty for scalars.
+	ProtoTypeName string
+	// OneofField is the TS field name of the enclosing OneofGroup if this
+	// field is a member of a real (>1 member) oneof, empty otherwise.
+	OneofField string
+	// OneofCase is this member's discriminant value ("case") within its
+	// OneofGroup, i.e. its own proto field name.
+	OneofCase string
+	// EnumZeroValue is the TS member name of a FieldKindEnum field's
+	// zero-numbered ("_UNSPECIFIED" by convention) value, used to detect its
+	// proto3 JSON default instead of a falsy check (enum values are
+	// non-empty strings, so they're never JS-falsy). Empty for non-enum
+	// fields.
+	EnumZeroValue string
 }
 
 type Service struct {
@@ -167,16 +363,27 @@ type Service struct {
 }
 
 type ServiceMethod struct {
-	Name       string
-	Path       string
-	InputArg   string
-	InputType  string
-	OutputType string
+	Name            string
+	Path            string
+	InputArg        string
+	InputType       string
+	OutputType      string
+	ServerStreaming bool
+	// InputTypeName/OutputTypeName are the fully-qualified proto names of
+	// InputType/OutputType, used to resolve which file (if not this one)
+	// needs to be imported for this method's signature.
+	InputTypeName  string
+	OutputTypeName string
 }
 
 type Enum struct {
 	Name   string
-	Values []string
+	Values []EnumValue
+}
+
+type EnumValue struct {
+	Name   string
+	Number int32
 }
 
 func NewAPIContext(twirpVersion string) APIContext {
@@ -187,60 +394,82 @@ func NewAPIContext(twirpVersion string) APIContext {
 
 	ctx := APIContext{TwirpPrefix: twirpPrefix}
 	ctx.modelLookup = make(map[string]*Model)
+	ctx.enumLookup = make(map[string]*Enum)
 
 	return ctx
 }
 
 type APIContext struct {
-	Package     string
-	Models      []*Model
-	Services    []*Service
-	Enums       []*Enum
-	TwirpPrefix string
-	modelLookup map[string]*Model
+	Package        string
+	Models         []*Model
+	Services       []*Service
+	Enums          []*Enum
+	Imports        []*Import
+	TwirpPrefix    string
+	EmitDefaults   bool
+	OrigNames      bool
+	Transport      string
+	WantsProtobuf  bool
+	WantsStreaming bool
+	modelLookup    map[string]*Model
+	enumLookup     map[string]*Enum
+	// fileOwner/filePackage/currentFile are only populated when this ctx is
+	// one of several built by Generate from a full CodeGeneratorRequest;
+	// they're nil (and harmlessly no-op in removePkg/AddModel) when an
+	// APIContext is used standalone, e.g. in tests.
+	fileOwner   map[string]string // fully-qualified proto name -> owning .proto filename
+	filePackage map[string]string // .proto filename -> its declared package
+	currentFile string
 }
 
-func (ctx *APIContext) AddModel(m *Model) {
-	ctx.Models = append(ctx.Models, m)
-	ctx.modelLookup[m.Name] = m
+// Import is a single cross-file `import { ... } from '...'` statement
+// needed by a generated client file, computed from which other file(s) its
+// Models/Services reference symbols from.
+type Import struct {
+	Path    string
+	Symbols []string
 }
 
-func getBaseType(f ModelField) string {
-	baseType := f.Type
-	if f.IsRepeated {
-		baseType = strings.Trim(baseType, "[]")
+// AddModel registers m under key, its fully-qualified proto name (e.g.
+// ".pkg.Outer.Inner"), so later field lookups can find it regardless of
+// whether its flattened TS name collides with an unrelated model's.
+func (ctx *APIContext) AddModel(key string, m *Model) {
+	ctx.Models = append(ctx.Models, m)
+	ctx.modelLookup[key] = m
+	if ctx.fileOwner != nil {
+		ctx.fileOwner[key] = ctx.currentFile
 	}
-
-	return baseType
 }
 
-// ApplyMarshalFlags will inspect the CanMarshal and CanUnmarshal flags for models where
-// the flags are enabled and recursively set the same values on all the models that are field types.
-func (ctx *APIContext) ApplyMarshalFlags() {
-	for _, m := range ctx.Models {
+// applyMarshalFlags inspects the CanMarshal and CanUnmarshal flags for
+// models where the flags are enabled and recursively sets the same values
+// on all the models that are field types, resolving field types through
+// modelLookup so a reference to a model declared in a different file still
+// works.
+func applyMarshalFlags(modelLookup map[string]*Model, models []*Model) {
+	for _, m := range models {
 		for _, f := range m.Fields {
 			// skip primitive types and WKT Timestamps
 			if !f.IsMessage || f.Type == "Date" {
 				continue
 			}
 
-			baseType := getBaseType(f)
 			if m.CanMarshal {
-				ctx.enableMarshal(ctx.modelLookup[baseType])
+				enableMarshal(modelLookup, modelLookup[f.ProtoTypeName])
 			}
 
 			if m.CanUnmarshal {
-				m, ok := ctx.modelLookup[baseType]
+				mm, ok := modelLookup[f.ProtoTypeName]
 				if !ok {
-					log.Fatalf("could not find model of type %s for field %s", baseType, f.Name)
+					log.Fatalf("could not find model of type %s for field %s", f.ProtoTypeName, f.Name)
 				}
-				ctx.enableUnmarshal(m)
+				enableUnmarshal(modelLookup, mm)
 			}
 		}
 	}
 }
 
-func (ctx *APIContext) enableMarshal(m *Model) {
+func enableMarshal(modelLookup map[string]*Model, m *Model) {
 	m.CanMarshal = true
 
 	for _, f := range m.Fields {
@@ -249,17 +478,15 @@ func (ctx *APIContext) enableMarshal(m *Model) {
 			continue
 		}
 
-		baseType := getBaseType(f)
-
-		mm, ok := ctx.modelLookup[baseType]
+		mm, ok := modelLookup[f.ProtoTypeName]
 		if !ok {
 			log.Fatalf("could not find model of type %s for field %s", f.Type, f.Name)
 		}
-		ctx.enableMarshal(mm)
+		enableMarshal(modelLookup, mm)
 	}
 }
 
-func (ctx *APIContext) enableUnmarshal(m *Model) {
+func enableUnmarshal(modelLookup map[string]*Model, m *Model) {
 	m.CanUnmarshal = true
 
 	for _, f := range m.Fields {
@@ -267,13 +494,12 @@ func (ctx *APIContext) enableUnmarshal(m *Model) {
 		if !f.IsMessage || f.Type == "Date" {
 			continue
 		}
-		baseType := getBaseType(f)
 
-		mm, ok := ctx.modelLookup[baseType]
+		mm, ok := modelLookup[f.ProtoTypeName]
 		if !ok {
 			log.Fatalf("could not find model of type %s for field %s", f.Type, f.Name)
 		}
-		ctx.enableUnmarshal(mm)
+		enableUnmarshal(modelLookup, mm)
 	}
 }
 
@@ -286,121 +512,126 @@ type Generator struct {
 	params       map[string]string
 }
 
-func (g *Generator) Generate(d *descriptor.FileDescriptorProto) ([]*plugin.CodeGeneratorResponse_File, error) {
-	var files []*plugin.CodeGeneratorResponse_File
-
-	// skip WKT Timestamp, we don't do any special serialization for jsonpb.
-	if *d.Name == "google/protobuf/timestamp.proto" {
-		return files, nil
+// Generate turns a full CodeGeneratorRequest into one .ts client module per
+// file named in FileToGenerate, plus a single shared './twirp' runtime
+// module. Every ProtoFile - including ones only present as a dependency -
+// is parsed into a shared, fully-qualified-name-keyed symbol table first
+// (see APIContext.fileOwner/filePackage), so a type referenced across a
+// file boundary resolves to a real `import` instead of an unresolved TS
+// name, and CanMarshal/CanUnmarshal propagate correctly even when the
+// model and the service that uses it live in different files.
+func (g *Generator) Generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	toGenerate := make(map[string]bool)
+	for _, name := range req.GetFileToGenerate() {
+		toGenerate[name] = true
 	}
 
-	ctx := NewAPIContext(g.twirpVersion)
-	ctx.Package = d.GetPackage()
+	modelLookup := make(map[string]*Model)
+	enumLookup := make(map[string]*Enum)
+	fileOwner := make(map[string]string)
+	filePackage := make(map[string]string)
+	contexts := make(map[string]*APIContext)
 
-	// TODO: This whole parsing code needs refactoring.
-	// It only supports one level of nesting which is done by duplicating
-	// code rather than using recursion
+	var allModels []*Model
+	var allServices []*Service
 
-	// Parse all enums for generating tpescript
-	for _, e := range d.GetEnumType() {
-		enum := &Enum{
-			Name: e.GetName(),
-		}
-		for _, ev := range e.GetValue() {
-			enum.Values = append(enum.Values, ev.GetName())
+	for _, d := range req.GetProtoFile() {
+		// skip WKT Timestamp, we don't do any special serialization for jsonpb.
+		if d.GetName() == "google/protobuf/timestamp.proto" {
+			continue
 		}
 
-		ctx.Enums = append(ctx.Enums, enum)
-	}
-
-	// Parse all Messages for generating typescript interfaces
-	for _, m := range d.GetMessageType() {
-		model := &Model{
-			Name: m.GetName(),
+		filePackage[d.GetName()] = d.GetPackage()
+
+		ctx := NewAPIContext(g.twirpVersion)
+		ctx.Package = d.GetPackage()
+		ctx.EmitDefaults = g.params["emit_defaults"] == "true"
+		ctx.OrigNames = g.params["orig_names"] == "true"
+		ctx.modelLookup = modelLookup
+		ctx.enumLookup = enumLookup
+		ctx.fileOwner = fileOwner
+		ctx.filePackage = filePackage
+		ctx.currentFile = d.GetName()
+
+		ctx.Transport = g.params["transport"]
+		if ctx.Transport == "" {
+			ctx.Transport = "json"
+		}
+		switch ctx.Transport {
+		case "json", "protobuf", "both":
+		default:
+			return nil, fmt.Errorf("transport param must be one of json, protobuf, both (got %q)", ctx.Transport)
+		}
+		ctx.WantsProtobuf = ctx.Transport != "json"
+
+		// Walk every top-level enum and message, recursing into nested types
+		// at any depth (see walk.go). Each top-level type's fully-qualified
+		// proto name starts from the file's package.
+		pkgPrefix := ""
+		if ctx.Package != "" {
+			pkgPrefix = "." + ctx.Package
 		}
 
-		// Parse all nested enums
-		for _, e := range m.GetEnumType() {
-			enum := &Enum{
-				Name: fmt.Sprintf("%s_%s", m.GetName(), e.GetName()),
-			}
-			for _, ev := range e.GetValue() {
-				enum.Values = append(enum.Values, ev.GetName())
-			}
-
-			ctx.Enums = append(ctx.Enums, enum)
+		for _, e := range d.GetEnumType() {
+			ctx.walkEnum(pkgPrefix+"."+e.GetName(), e)
 		}
 
-		// Parse all nested models
-		for _, m2 := range m.GetNestedType() {
-			nestedModel := &Model{
-				Name: fmt.Sprintf("%s_%s", m.GetName(), m2.GetName()),
-			}
+		for _, m := range d.GetMessageType() {
+			ctx.walkMessage(pkgPrefix+"."+m.GetName(), m)
+		}
 
-			if m2.Options.GetMapEntry() {
-				nestedModel.IsMap = true
+		// Parse all Services for generating typescript method interfaces and default client implementations
+		for _, s := range d.GetService() {
+			service := &Service{
+				Name:    s.GetName(),
+				Package: ctx.Package,
 			}
 
-			for _, f2 := range m2.GetField() {
-				mf := ctx.newField(f2)
-				if nestedModel.IsMap && mf.Name == "value" {
-					nestedModel.MapValueType = mf.Type
-					if f2.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
-						nestedModel.MapValueTypePrimitive = true
-					}
+			for _, m := range s.GetMethod() {
+				if m.GetClientStreaming() {
+					return nil, fmt.Errorf("%s.%s: client-streaming and bidirectional-streaming RPCs are not supported by this generator, only server-streaming", s.GetName(), m.GetName())
 				}
-				nestedModel.Fields = append(nestedModel.Fields, mf)
-
-			}
-
-			ctx.AddModel(nestedModel)
-		}
 
-		for _, f := range m.GetField() {
-			f3 := ctx.newField(f)
+				methodPath := m.GetName()
+				methodName := strings.ToLower(methodPath[0:1]) + methodPath[1:]
+				in := ctx.removePkg(m.GetInputType())
+				arg := strings.ToLower(in[0:1]) + in[1:]
+
+				method := ServiceMethod{
+					Name:            methodName,
+					Path:            methodPath,
+					InputArg:        arg,
+					InputType:       in,
+					InputTypeName:   m.GetInputType(),
+					OutputType:      ctx.removePkg(m.GetOutputType()),
+					OutputTypeName:  m.GetOutputType(),
+					ServerStreaming: m.GetServerStreaming(),
+				}
+				if method.ServerStreaming {
+					ctx.WantsStreaming = true
+				}
 
-			ml, ok := ctx.modelLookup[ctx.removePkg(f.GetTypeName())]
-			if ok && ml.IsMap && ml.MapValueTypePrimitive {
-				f3.MapValueTypePrimitive = true
+				service.Methods = append(service.Methods, method)
 			}
 
-			model.Fields = append(model.Fields, f3)
+			ctx.Services = append(ctx.Services, service)
 		}
 
-		ctx.AddModel(model)
-	}
-
-	// Parse all Services for generating typescript method interfaces and default client implementations
-	for _, s := range d.GetService() {
-		service := &Service{
-			Name:    s.GetName(),
-			Package: ctx.Package,
-		}
-
-		for _, m := range s.GetMethod() {
-			methodPath := m.GetName()
-			methodName := strings.ToLower(methodPath[0:1]) + methodPath[1:]
-			in := ctx.removePkg(m.GetInputType())
-			arg := strings.ToLower(in[0:1]) + in[1:]
-
-			method := ServiceMethod{
-				Name:       methodName,
-				Path:       methodPath,
-				InputArg:   arg,
-				InputType:  in,
-				OutputType: ctx.removePkg(m.GetOutputType()),
-			}
-
-			service.Methods = append(service.Methods, method)
-		}
+		ctx.AddModel("Date", &Model{
+			Name:      "Date",
+			Primitive: true,
+		})
 
-		ctx.Services = append(ctx.Services, service)
+		contexts[d.GetName()] = &ctx
+		allModels = append(allModels, ctx.Models...)
+		allServices = append(allServices, ctx.Services...)
 	}
 
-	// Only include the custom 'ToJSON' and 'JSONTo' methods in generated code
-	// if the Model is part of an rpc method input arg or return type.
-	for _, m := range ctx.Models {
-		for _, s := range ctx.Services {
+	// Only include the custom 'ToJSON'/'JSONTo'/'encode'/'decode' helpers
+	// for a Model if it's part of some rpc method's input arg or return
+	// type, even if that method is declared in a different file.
+	for _, m := range allModels {
+		for _, s := range allServices {
 			for _, sm := range s.Methods {
 				if m.Name == sm.InputType {
 					m.CanMarshal = true
@@ -413,34 +644,49 @@ func (g *Generator) Generate(d *descriptor.FileDescriptorProto) ([]*plugin.CodeG
 		}
 	}
 
-	ctx.AddModel(&Model{
-		Name:      "Date",
-		Primitive: true,
-	})
+	applyMarshalFlags(modelLookup, allModels)
 
-	ctx.ApplyMarshalFlags()
+	var files []*plugin.CodeGeneratorResponse_File
 
-	funcMap := template.FuncMap{
-		"stringify": stringify,
-		"parse":     parse,
-	}
+	for _, d := range req.GetProtoFile() {
+		if !toGenerate[d.GetName()] {
+			continue
+		}
 
-	t, err := template.New("client_api").Funcs(funcMap).Parse(apiTemplate)
-	if err != nil {
-		return nil, err
-	}
+		ctx, ok := contexts[d.GetName()]
+		if !ok {
+			// Only file we skip building a context for is the WKT Timestamp.
+			continue
+		}
 
-	b := bytes.NewBufferString("")
-	err = t.Execute(b, ctx)
-	if err != nil {
-		return nil, err
-	}
+		ctx.Imports = collectImports(ctx)
+
+		funcMap := template.FuncMap{
+			"stringify":       func(f ModelField) string { return stringify(f, ctx.EmitDefaults) },
+			"parse":           parse,
+			"parseOneof":      parseOneof,
+			"protoEncode":     func(f ModelField) string { return protoEncodeField(ctx, f) },
+			"protoDecodeCase": func(f ModelField) string { return protoDecodeCase(ctx, f) },
+		}
 
-	clientAPI := &plugin.CodeGeneratorResponse_File{}
-	clientAPI.Name = proto.String(tsModuleFilename(d))
-	clientAPI.Content = proto.String(b.String())
+		t, err := template.New("client_api").Funcs(funcMap).Parse(apiTemplate)
+		if err != nil {
+			return nil, err
+		}
+
+		b := bytes.NewBufferString("")
+		err = t.Execute(b, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		clientAPI := &plugin.CodeGeneratorResponse_File{}
+		clientAPI.Name = proto.String(tsModuleFilename(d))
+		clientAPI.Content = proto.String(b.String())
+
+		files = append(files, clientAPI)
+	}
 
-	files = append(files, clientAPI)
 	files = append(files, RuntimeLibrary())
 
 	if pkgName, ok := g.params["package_name"]; ok {
@@ -457,17 +703,107 @@ func (g *Generator) Generate(d *descriptor.FileDescriptorProto) ([]*plugin.CodeG
 	return files, nil
 }
 
-func tsModuleFilename(f *descriptor.FileDescriptorProto) string {
-	name := *f.Name
+// collectImports scans every Model and Service method belonging to ctx's
+// file for references to a type owned by a different file, and groups the
+// TS symbols those references need into one Import per foreign file.
+func collectImports(ctx *APIContext) []*Import {
+	bySymbolFile := make(map[string]map[string]bool)
+
+	addImport := func(protoTypeName, tsType string, isEnum bool) {
+		if protoTypeName == "" {
+			return
+		}
+
+		owner, ok := ctx.fileOwner[protoTypeName]
+		if !ok || owner == ctx.currentFile {
+			return
+		}
+
+		base := tsModuleBaseName(owner)
+		syms, ok := bySymbolFile[base]
+		if !ok {
+			syms = make(map[string]bool)
+			bySymbolFile[base] = syms
+		}
+
+		syms[tsType] = true
+
+		if isEnum {
+			if ctx.WantsProtobuf {
+				syms[tsType+"Number"] = true
+				syms[tsType+"FromNumber"] = true
+			}
+			return
+		}
+
+		syms[tsType+"JSON"] = true
 
-	if ext := path.Ext(name); ext == ".proto" || ext == ".protodevel" {
-		base := path.Base(name)
-		name = base[:len(base)-len(path.Ext(base))]
+		m := ctx.modelLookup[protoTypeName]
+		if m == nil {
+			return
+		}
+		if m.CanMarshal {
+			syms[tsType+"ToJSON"] = true
+			if ctx.WantsProtobuf {
+				syms["encode"+tsType] = true
+			}
+		}
+		if m.CanUnmarshal {
+			syms["JSONTo"+tsType] = true
+			if ctx.WantsProtobuf {
+				syms["decode"+tsType] = true
+			}
+		}
 	}
 
-	name += ".ts"
+	for _, m := range ctx.Models {
+		for _, f := range m.Fields {
+			addImport(f.ProtoTypeName, strings.TrimSuffix(f.Type, "[]"), f.Kind == FieldKindEnum)
+		}
+	}
 
-	return name
+	for _, s := range ctx.Services {
+		for _, sm := range s.Methods {
+			addImport(sm.InputTypeName, sm.InputType, false)
+			addImport(sm.OutputTypeName, sm.OutputType, false)
+		}
+	}
+
+	var bases []string
+	for base := range bySymbolFile {
+		bases = append(bases, base)
+	}
+	sort.Strings(bases)
+
+	imports := make([]*Import, 0, len(bases))
+	for _, base := range bases {
+		var symbols []string
+		for s := range bySymbolFile[base] {
+			symbols = append(symbols, s)
+		}
+		sort.Strings(symbols)
+
+		imports = append(imports, &Import{Path: "./" + base, Symbols: symbols})
+	}
+
+	return imports
+}
+
+func tsModuleFilename(f *descriptor.FileDescriptorProto) string {
+	return tsModuleBaseName(f.GetName()) + ".ts"
+}
+
+// tsModuleBaseName strips a .proto filename down to the base name its
+// generated client module is imported by, e.g. "sub/common.proto" -> "common".
+// Generated modules are all emitted flat (see tsModuleFilename), so this is
+// also the relative import path's final segment for a cross-file reference.
+func tsModuleBaseName(protoFilename string) string {
+	base := path.Base(protoFilename)
+	if ext := path.Ext(base); ext == ".proto" || ext == ".protodevel" {
+		base = base[:len(base)-len(ext)]
+	}
+
+	return base
 }
 
 func (c *APIContext) newField(f *descriptor.FieldDescriptorProto) ModelField {
@@ -475,18 +811,81 @@ func (c *APIContext) newField(f *descriptor.FieldDescriptorProto) ModelField {
 		Name: camelCase(f.GetName()),
 	}
 
-	if m, ok := c.modelLookup[c.removePkg(f.GetTypeName())]; ok {
+	if f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE || f.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM {
+		field.ProtoTypeName = f.GetTypeName()
+	}
+
+	if m, ok := c.modelLookup[f.GetTypeName()]; ok {
 		field.IsMap = m.IsMap
+		field.MapValueTypePrimitive = m.MapValueTypePrimitive
 	}
 
 	field.Type, field.JSONType = c.protoToTSType(f, field)
-	field.JSONName = f.GetName()
-	field.IsMessage = f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE && !(f.GetTypeName() == ".google.protobuf.Timestamp")
+	field.Kind = fieldKind(f)
+	if field.Kind == FieldKindEnum {
+		if e, ok := c.enumLookup[field.ProtoTypeName]; ok {
+			field.EnumZeroValue = enumZeroValueName(e)
+		}
+	}
+	field.Number = f.GetNumber()
+	wi := fieldWireInfo(f)
+	field.WireType = wi.WireType
+	field.ZigZag = wi.ZigZag
+	field.Signed = wi.Signed
+	field.Packed = wi.Packable && isRepeated(f) && !field.IsMap
+	if c.OrigNames {
+		field.JSONName = f.GetName()
+	} else {
+		field.JSONName = camelCase(f.GetName())
+	}
+	field.IsMessage = f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE &&
+		f.GetTypeName() != ".google.protobuf.Timestamp" &&
+		!isWellKnownScalar(f.GetTypeName())
 	field.IsRepeated = isRepeated(f)
 
 	return field
 }
 
+// enumZeroValueName returns the TS member name of e's zero-numbered value -
+// proto3 requires every enum to declare one, conventionally named
+// "..._UNSPECIFIED" - or "" if e somehow doesn't declare one.
+func enumZeroValueName(e *Enum) string {
+	for _, v := range e.Values {
+		if v.Number == 0 {
+			return v.Name
+		}
+	}
+
+	return ""
+}
+
+// fieldKind classifies a field according to the canonical proto3 JSON
+// mapping so the template can select the right marshal/unmarshal snippet.
+// See FieldKind for the full list of special cases.
+func fieldKind(f *descriptor.FieldDescriptorProto) FieldKind {
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return FieldKindInt64
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return FieldKindFloat
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return FieldKindBytes
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return FieldKindEnum
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if wkt, ok := wellKnownTypes[f.GetTypeName()]; ok {
+			return wkt.Kind
+		}
+	}
+
+	return FieldKindDefault
+}
+
 // generates the (Type, JSONType) tuple for a ModelField so marshal/unmarshal functions
 // will work when converting between TS interfaces and protobuf JSON.
 func (c *APIContext) protoToTSType(f *descriptor.FieldDescriptorProto, mf ModelField) (string, string) {
@@ -495,18 +894,33 @@ func (c *APIContext) protoToTSType(f *descriptor.FieldDescriptorProto, mf ModelF
 
 	switch f.GetType() {
 	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FLOAT,
 		descriptor.FieldDescriptorProto_TYPE_FIXED32,
-		descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32,
 		descriptor.FieldDescriptorProto_TYPE_INT32,
-		descriptor.FieldDescriptorProto_TYPE_INT64:
+		descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32:
 		tsType = "number"
 		jsonType = "number"
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64:
+		// 64-bit ints don't fit in a JS number without precision loss, so
+		// the TS side uses bigint while the wire format (per jsonpb) is a
+		// decimal string.
+		tsType = "bigint"
+		jsonType = "string"
 	case descriptor.FieldDescriptorProto_TYPE_STRING:
 		tsType = "string"
 		jsonType = "string"
 	case descriptor.FieldDescriptorProto_TYPE_BOOL:
 		tsType = "boolean"
 		jsonType = "boolean"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		tsType = "Uint8Array"
+		jsonType = "string"
 	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
 		name := f.GetTypeName()
 
@@ -518,6 +932,9 @@ func (c *APIContext) protoToTSType(f *descriptor.FieldDescriptorProto, mf ModelF
 		if name == ".google.protobuf.Timestamp" {
 			tsType = "string"
 			jsonType = "string"
+		} else if wkt, ok := wellKnownTypes[name]; ok {
+			tsType = wkt.TSType
+			jsonType = wkt.JSONType
 		} else {
 			tsType = c.removePkg(name)
 			jsonType = c.removePkg(name) + "JSON"
@@ -540,10 +957,27 @@ func isRepeated(field *descriptor.FieldDescriptorProto) bool {
 	return field.Label != nil && *field.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED
 }
 
+// removePkg flattens a fully-qualified proto name s (e.g. ".pkg.Outer.Inner")
+// down to its TS name (e.g. "Outer_Inner") by stripping s's own declared
+// package prefix and joining the rest with underscores. s isn't always
+// declared in c.Package - it may be a cross-file reference - so its real
+// owning package is looked up via fileOwner/filePackage when known, falling
+// back to c.Package (correct for anything declared in the file c itself is
+// walking, including before it's been registered in fileOwner yet).
 func (c *APIContext) removePkg(s string) string {
-	s2 := strings.ReplaceAll(s, c.Package, "")
-	s3 := strings.TrimLeft(s2, ".")
-	return strings.ReplaceAll(s3, ".", "_")
+	pkg := c.Package
+	if owner, ok := c.fileOwner[s]; ok {
+		if p, ok := c.filePackage[owner]; ok {
+			pkg = p
+		}
+	}
+
+	rest := strings.TrimPrefix(s, ".")
+	if pkg != "" {
+		rest = strings.TrimPrefix(rest, pkg+".")
+	}
+
+	return strings.ReplaceAll(rest, ".", "_")
 }
 
 func camelCase(s string) string {
@@ -560,28 +994,107 @@ func camelCase(s string) string {
 	return strings.Join(parts, "")
 }
 
-func stringify(f ModelField) string {
+// marshalScalar returns the expression that converts a single TS scalar
+// value `expr` (already known to be of kind f.Kind) into its proto3 JSON
+// representation. It does not handle repeated/array wrapping; callers
+// apply that separately so the same per-element snippet works for both
+// singular and repeated fields.
+func marshalScalar(f ModelField, expr string) string {
+	switch f.Kind {
+	case FieldKindInt64:
+		return fmt.Sprintf("%s.toString()", expr)
+	case FieldKindFloat:
+		return fmt.Sprintf("encodeFloat(%s)", expr)
+	case FieldKindBytes:
+		return fmt.Sprintf("bytesToBase64(%s)", expr)
+	case FieldKindFieldMask:
+		return fmt.Sprintf("%s.join(\",\")", expr)
+	default:
+		return expr
+	}
+}
+
+// unmarshalScalar is the inverse of marshalScalar: it converts a single
+// proto3 JSON scalar `expr` back into its TS representation.
+func unmarshalScalar(f ModelField, expr string) string {
+	switch f.Kind {
+	case FieldKindInt64:
+		return fmt.Sprintf("BigInt(%s)", expr)
+	case FieldKindFloat:
+		return fmt.Sprintf("decodeFloat(%s)", expr)
+	case FieldKindBytes:
+		return fmt.Sprintf("base64ToBytes(%s)", expr)
+	case FieldKindFieldMask:
+		return fmt.Sprintf("%s.split(\",\")", expr)
+	default:
+		return expr
+	}
+}
+
+func stringify(f ModelField, emitDefaults bool) string {
+	field := "m." + f.Name
+	if f.OneofField != "" {
+		field = fmt.Sprintf("(m.%s && m.%s.case === %q ? m.%s.value : undefined)", f.OneofField, f.OneofField, f.OneofCase, f.OneofField)
+	}
+
 	if f.IsRepeated && !f.IsMap {
 		singularType := strings.Trim(f.Type, "[]") // strip array brackets from type
 
-		if f.Type == "Date" {
-			return fmt.Sprintf("m.%s && m.%s.map((n) => n.toISOString())", f.Name, f.Name)
+		if f.Type == "Date[]" {
+			return fmt.Sprintf("%s && %s.map((n) => n.toISOString())", field, field)
 		}
 
 		if f.IsMessage {
-			return fmt.Sprintf("m.%s && m.%s.map(%sToJSON)", f.Name, f.Name, singularType)
+			return fmt.Sprintf("%s && %s.map(%sToJSON)", field, field, singularType)
 		}
+
+		if f.Kind != FieldKindDefault && f.Kind != FieldKindEnum {
+			return fmt.Sprintf("%s && %s.map((n) => %s)", field, field, marshalScalar(f, "n"))
+		}
+
+		return field
 	}
 
 	if f.Type == "Date" {
-		return fmt.Sprintf("m.%s && m.%s.toISOString()", f.Name, f.Name)
+		return fmt.Sprintf("%s && %s.toISOString()", field, field)
 	}
 
 	if f.IsMessage && !f.MapValueTypePrimitive {
-		return fmt.Sprintf("m.%s && %sToJSON(m.%s)", f.Name, f.Type, f.Name)
+		return fmt.Sprintf("%s && %sToJSON(%s)", field, f.Type, field)
+	}
+
+	if !emitDefaults && f.OneofField == "" && !isWellKnownScalar(f.ProtoTypeName) {
+		// Zero-valued int64, float and bytes fields are omitted as well;
+		// well-known wrappers and oneof members keep theirs.
+		switch f.Kind {
+		case FieldKindInt64:
+			return fmt.Sprintf("%s !== undefined && %s !== BigInt(0) ? %s : undefined", field, field, marshalScalar(f, field))
+		case FieldKindFloat:
+			return fmt.Sprintf("%s !== undefined && %s !== 0 ? %s : undefined", field, field, marshalScalar(f, field))
+		case FieldKindBytes:
+			return fmt.Sprintf("%s !== undefined && %s.length > 0 ? %s : undefined", field, field, marshalScalar(f, field))
+		}
+	}
+
+	if f.Kind != FieldKindDefault && f.Kind != FieldKindEnum {
+		return fmt.Sprintf("%s !== undefined ? %s : undefined", field, marshalScalar(f, field))
 	}
 
-	return "m." + f.Name
+	if !emitDefaults && f.Kind == FieldKindDefault && !f.IsMessage {
+		// jsonpb's default (emit_defaults=false) omits zero-valued scalars
+		// from the wire payload rather than sending e.g. 0/""/false.
+		return fmt.Sprintf("%s || undefined", field)
+	}
+
+	if !emitDefaults && f.Kind == FieldKindEnum {
+		// Same omission as above, but an enum's zero ("_UNSPECIFIED") value
+		// is a non-empty TS string, so it's never JS-falsy - compare against
+		// the zero member by name instead of reusing the `field || undefined`
+		// check above.
+		return fmt.Sprintf("%s !== %q ? %s : undefined", field, f.EnumZeroValue, field)
+	}
+
+	return field
 }
 
 func parse(f ModelField, modelName string) string {
@@ -597,6 +1110,12 @@ func parse(f ModelField, modelName string) string {
 		if f.IsMessage {
 			return fmt.Sprintf("%s && %s.map(JSONTo%s)", field, field, singularTSType)
 		}
+
+		if f.Kind != FieldKindDefault && f.Kind != FieldKindEnum {
+			return fmt.Sprintf("%s && %s.map((n) => %s)", field, field, unmarshalScalar(f, "n"))
+		}
+
+		return field
 	}
 
 	if f.Type == "Date" {
@@ -607,5 +1126,23 @@ func parse(f ModelField, modelName string) string {
 		return fmt.Sprintf("%s && JSONTo%s(%s)", field, f.Type, field)
 	}
 
+	if f.Kind != FieldKindDefault && f.Kind != FieldKindEnum {
+		return fmt.Sprintf("%s !== undefined ? %s : undefined", field, unmarshalScalar(f, field))
+	}
+
 	return field
 }
+
+// parseOneof assembles a OneofGroup's discriminated-union value out of
+// whichever member is present in the proto3 JSON object `m`, which encodes
+// a oneof the same way as any other optional field: as a flat, unwrapped
+// key per member.
+func parseOneof(group *OneofGroup, modelName string) string {
+	expr := "undefined"
+	for i := len(group.Members) - 1; i >= 0; i-- {
+		member := group.Members[i]
+		expr = fmt.Sprintf("m.%s !== undefined ? { case: %q as const, value: %s } : %s", member.JSONName, member.OneofCase, parse(member, modelName), expr)
+	}
+
+	return expr
+}
@@ -0,0 +1,84 @@
+package minimal
+
+import "testing"
+
+// marshalScalar must emit the proto3 JSON canonical representation for each
+// scalar kind that differs from its TS representation - plain passthrough is
+// only correct for kinds JSON and TS agree on already.
+func TestMarshalScalar(t *testing.T) {
+	tests := []struct {
+		name string
+		kind FieldKind
+		want string
+	}{
+		{"int64 as decimal string", FieldKindInt64, "v.toString()"},
+		{"float via NaN/Infinity-aware helper", FieldKindFloat, "encodeFloat(v)"},
+		{"bytes as base64 string", FieldKindBytes, "bytesToBase64(v)"},
+		{"field mask as comma-joined string", FieldKindFieldMask, `v.join(",")`},
+		{"plain scalar passthrough", FieldKindDefault, "v"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := marshalScalar(ModelField{Kind: tt.kind}, "v")
+			if got != tt.want {
+				t.Errorf("marshalScalar(%v, %q) = %q, want %q", tt.kind, "v", got, tt.want)
+			}
+		})
+	}
+}
+
+// unmarshalScalar is the inverse of marshalScalar and must round-trip through
+// the same proto3 JSON representation.
+func TestUnmarshalScalar(t *testing.T) {
+	tests := []struct {
+		name string
+		kind FieldKind
+		want string
+	}{
+		{"decimal string to bigint", FieldKindInt64, "BigInt(v)"},
+		{"NaN/Infinity-aware string or number to number", FieldKindFloat, "decodeFloat(v)"},
+		{"base64 string to bytes", FieldKindBytes, "base64ToBytes(v)"},
+		{"comma-joined string to field mask", FieldKindFieldMask, `v.split(",")`},
+		{"plain scalar passthrough", FieldKindDefault, "v"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unmarshalScalar(ModelField{Kind: tt.kind}, "v")
+			if got != tt.want {
+				t.Errorf("unmarshalScalar(%v, %q) = %q, want %q", tt.kind, "v", got, tt.want)
+			}
+		})
+	}
+}
+
+// stringify must honor emit_defaults=false for an enum field's zero value
+// exactly like it does for every other scalar kind, even though an enum's
+// zero value is a non-empty (and so never JS-falsy) TS string.
+func TestStringifyEnumOmitsDefault(t *testing.T) {
+	field := ModelField{Name: "status", Kind: FieldKindEnum, EnumZeroValue: "STATUS_UNSPECIFIED"}
+
+	got := stringify(field, false)
+	want := `m.status !== "STATUS_UNSPECIFIED" ? m.status : undefined`
+	if got != want {
+		t.Errorf("stringify(enum, emitDefaults=false) = %q, want %q", got, want)
+	}
+
+	if got := stringify(field, true); got != "m.status" {
+		t.Errorf("stringify(enum, emitDefaults=true) = %q, want plain passthrough", got)
+	}
+}
+
+// enumZeroValueName must find the zero-numbered member regardless of its
+// position in the declaration, since proto3 doesn't require it to be first.
+func TestEnumZeroValueName(t *testing.T) {
+	e := &Enum{Values: []EnumValue{
+		{Name: "STATUS_ACTIVE", Number: 1},
+		{Name: "STATUS_UNSPECIFIED", Number: 0},
+	}}
+
+	if got := enumZeroValueName(e); got != "STATUS_UNSPECIFIED" {
+		t.Errorf("enumZeroValueName = %q, want STATUS_UNSPECIFIED", got)
+	}
+}
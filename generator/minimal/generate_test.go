@@ -0,0 +1,79 @@
+package minimal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// Generate must resolve a field that references a message declared in a
+// different .proto file: the referencing file's output imports the foreign
+// type (and its ToJSON/JSONTo helpers) from the owning file's generated
+// module, and CanMarshal/CanUnmarshal propagate onto that foreign model
+// through the reference even though it's never itself a method's direct
+// input/output type.
+func TestGenerateCrossFileImport(t *testing.T) {
+	commonFile := &descriptor.FileDescriptorProto{
+		Name:    proto.String("common.proto"),
+		Package: proto.String("common"),
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name:  proto.String("Common"),
+				Field: []*descriptor.FieldDescriptorProto{strField("name", 1)},
+			},
+		},
+	}
+
+	serviceFile := &descriptor.FileDescriptorProto{
+		Name:       proto.String("service.proto"),
+		Package:    proto.String("svc"),
+		Dependency: []string{"common.proto"},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name:  proto.String("Req"),
+				Field: []*descriptor.FieldDescriptorProto{msgField("common", 1, ".common.Common")},
+			},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("Svc"),
+				Method: []*descriptor.MethodDescriptorProto{
+					{
+						Name:       proto.String("Do"),
+						InputType:  proto.String(".svc.Req"),
+						OutputType: proto.String(".svc.Req"),
+					},
+				},
+			},
+		},
+	}
+
+	g := NewGenerator("v7", map[string]string{})
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{"service.proto"},
+		ProtoFile:      []*descriptor.FileDescriptorProto{commonFile, serviceFile},
+	}
+
+	files, err := g.Generate(req)
+	if err != nil {
+		t.Fatalf("Generate returned an error: %v", err)
+	}
+
+	var content string
+	for _, f := range files {
+		if f.GetName() == "service.ts" {
+			content = f.GetContent()
+		}
+	}
+	if content == "" {
+		t.Fatalf("expected a service.ts file among %d generated files", len(files))
+	}
+
+	wantImport := "import { Common, CommonJSON, CommonToJSON, JSONToCommon } from './common';"
+	if !strings.Contains(content, wantImport) {
+		t.Errorf("service.ts should import Common's marshal/unmarshal helpers from common.ts, got:\n%s", content)
+	}
+}
@@ -0,0 +1,88 @@
+package minimal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// CreatePackageIndex builds index.ts, re-exporting every symbol from every
+// generated client module (one per proto file) plus the shared runtime, so
+// a package_name consumer gets one coherent public surface - `import {
+// Foo } from 'my-package'` - instead of reaching into individual generated
+// files.
+func CreatePackageIndex(files []*plugin.CodeGeneratorResponse_File) (*plugin.CodeGeneratorResponse_File, error) {
+	var modules []string
+	for _, f := range files {
+		name := f.GetName()
+		if !strings.HasSuffix(name, ".ts") {
+			continue
+		}
+		modules = append(modules, strings.TrimSuffix(name, ".ts"))
+	}
+	sort.Strings(modules)
+
+	b := &strings.Builder{}
+	for _, m := range modules {
+		fmt.Fprintf(b, "export * from './%s';\n", m)
+	}
+
+	idx := &plugin.CodeGeneratorResponse_File{}
+	idx.Name = proto.String("index.ts")
+	idx.Content = proto.String(b.String())
+
+	return idx, nil
+}
+
+const tsConfigTemplate = `{
+  "compilerOptions": {
+    "target": "ES2017",
+    "module": "commonjs",
+    "lib": ["ES2017", "DOM"],
+    "declaration": true,
+    "outDir": "./dist",
+    "strict": true,
+    "esModuleInterop": true
+  },
+  "include": ["*.ts"]
+}
+`
+
+// CreateTSConfig returns the tsconfig.json shipped alongside a package_name
+// build, just enough to compile the generated .ts modules to commonjs + d.ts
+// output under ./dist.
+func CreateTSConfig() *plugin.CodeGeneratorResponse_File {
+	f := &plugin.CodeGeneratorResponse_File{}
+	f.Name = proto.String("tsconfig.json")
+	f.Content = proto.String(tsConfigTemplate)
+
+	return f
+}
+
+const packageJSONTemplate = `{
+  "name": "%s",
+  "version": "0.0.1",
+  "main": "dist/index.js",
+  "types": "dist/index.d.ts",
+  "scripts": {
+    "build": "tsc"
+  },
+  "devDependencies": {
+    "typescript": "^4.9.0"
+  }
+}
+`
+
+// CreatePackageJSON returns the package.json shipped alongside a
+// package_name build, naming the package pkgName and pointing `main`/
+// `types` at the tsc output CreateTSConfig produces.
+func CreatePackageJSON(pkgName string) *plugin.CodeGeneratorResponse_File {
+	f := &plugin.CodeGeneratorResponse_File{}
+	f.Name = proto.String("package.json")
+	f.Content = proto.String(fmt.Sprintf(packageJSONTemplate, pkgName))
+
+	return f
+}
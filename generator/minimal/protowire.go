@@ -0,0 +1,165 @@
+package minimal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// wktWireUnsupported reports whether f is one of the google.protobuf
+// well-known scalar kinds (Duration, FieldMask, Struct, Value, ListValue,
+// Any, Empty, or a *Value wrapper) that this generator only knows how to
+// marshal over application/json; see wkt.go.
+func wktWireUnsupported(f ModelField) bool {
+	if isWellKnownScalar(f.ProtoTypeName) {
+		return true
+	}
+
+	switch f.Kind {
+	case FieldKindDuration, FieldKindFieldMask, FieldKindStruct, FieldKindValue, FieldKindListValue, FieldKindAny, FieldKindEmpty, FieldKindWrapper:
+		return true
+	default:
+		return false
+	}
+}
+
+// protoWriteCall returns the Writer method call (sans the "w." or "sub."
+// receiver) that encodes a single scalar/message value already bound to
+// expr.
+func protoWriteCall(f ModelField, expr string) string {
+	typ := strings.TrimSuffix(f.Type, "[]")
+	if f.IsMessage {
+		return fmt.Sprintf("bytes(encode%s(%s))", typ, expr)
+	}
+	if f.Kind == FieldKindEnum {
+		return fmt.Sprintf("int32(%sNumber[%s])", typ, expr)
+	}
+
+	return fmt.Sprintf("%s(%s)", wireWriteMethod(f), expr)
+}
+
+// protoReadCall returns the Reader method call (sans the receiver, which
+// the caller supplies via readerVar) that decodes a single scalar/message
+// value of f's type.
+func protoReadCall(f ModelField, readerVar string) string {
+	typ := strings.TrimSuffix(f.Type, "[]")
+	if f.IsMessage {
+		return fmt.Sprintf("decode%s(%s.bytes())", typ, readerVar)
+	}
+	if f.Kind == FieldKindEnum {
+		return fmt.Sprintf("%sFromNumber(%s.int32())", typ, readerVar)
+	}
+
+	return fmt.Sprintf("%s.%s()", readerVar, wireReadMethod(f))
+}
+
+// protoEncodeField returns the statement(s), for use inside encode{Model},
+// that append field f of `m` to the in-scope Writer `w`.
+func protoEncodeField(ctx *APIContext, f ModelField) string {
+	if wktWireUnsupported(f) {
+		return fmt.Sprintf("// %s: google.protobuf well-known type fields are only supported over application/json, not application/protobuf", f.Name)
+	}
+
+	if f.OneofField != "" {
+		return fmt.Sprintf(`if (m.%s && m.%s.case === %q) {
+		w.tag(%d, %d).%s;
+	}`, f.OneofField, f.OneofField, f.OneofCase, f.Number, f.WireType, protoWriteCall(f, fmt.Sprintf("m.%s.value", f.OneofField)))
+	}
+
+	if f.IsMap {
+		// A proto map field is wire-compatible with `repeated MapEntry`,
+		// where MapEntry is the synthetic two-field (key=1, value=2)
+		// message the parser already generated a Model for (see
+		// walkMessage). Reuse its own encode{Entry}
+		// rather than re-deriving the key/value wire layout here.
+		entry := ctx.modelLookup[f.ProtoTypeName]
+		if entry == nil {
+			return fmt.Sprintf("// %s: could not resolve map entry type %s", f.Name, f.Type)
+		}
+
+		return fmt.Sprintf(`if (m.%s) {
+		for (const key of Object.keys(m.%s)) {
+			w.tag(%d, wireLengthDelimited).bytes(encode%s({ key, value: m.%s[key] }));
+		}
+	}`, f.Name, f.Name, f.Number, f.Type, f.Name)
+	}
+
+	if f.IsRepeated {
+		if f.Packed {
+			return fmt.Sprintf(`if (m.%s && m.%s.length) {
+		w.tag(%d, wireLengthDelimited).fork();
+		for (const v of m.%s) {
+			w.%s;
+		}
+		w.ldelim();
+	}`, f.Name, f.Name, f.Number, f.Name, protoWriteCall(f, "v"))
+		}
+
+		return fmt.Sprintf(`if (m.%s) {
+		for (const v of m.%s) {
+			w.tag(%d, %d).%s;
+		}
+	}`, f.Name, f.Name, f.Number, f.WireType, protoWriteCall(f, "v"))
+	}
+
+	return fmt.Sprintf(`if (m.%s !== undefined) {
+		w.tag(%d, %d).%s;
+	}`, f.Name, f.Number, f.WireType, protoWriteCall(f, "m."+f.Name))
+}
+
+// protoDecodeCase returns the `case <fieldNumber>:` clause, for use inside
+// decode{Model}'s field-number switch, that reads field f from the reader
+// `r` into `m`.
+func protoDecodeCase(ctx *APIContext, f ModelField) string {
+	if wktWireUnsupported(f) {
+		return fmt.Sprintf(`case %d:
+			// %s: google.protobuf well-known type fields are only supported over application/json
+			r.skip(wireType);
+			break;`, f.Number, f.Name)
+	}
+
+	if f.OneofField != "" {
+		return fmt.Sprintf(`case %d:
+			m.%s = { case: %q, value: %s };
+			break;`, f.Number, f.OneofField, f.OneofCase, protoReadCall(f, "r"))
+	}
+
+	if f.IsMap {
+		entry := ctx.modelLookup[f.ProtoTypeName]
+		if entry == nil {
+			return fmt.Sprintf(`case %d:
+			r.skip(wireType);
+			break;`, f.Number)
+		}
+
+		return fmt.Sprintf(`case %d: {
+			const entry = decode%s(r.bytes());
+			m.%s = m.%s || {};
+			if (entry.key !== undefined) {
+				m.%s[entry.key] = entry.value as %s;
+			}
+			break;
+		}`, f.Number, f.Type, f.Name, f.Name, f.Name, entry.MapValueType)
+	}
+
+	if f.IsRepeated {
+		if f.Packed {
+			return fmt.Sprintf(`case %d: {
+			const sub = new Reader(r.bytes());
+			m.%s = m.%s || [];
+			while (sub.pos < sub.len) {
+				m.%s.push(%s);
+			}
+			break;
+		}`, f.Number, f.Name, f.Name, f.Name, protoReadCall(f, "sub"))
+		}
+
+		return fmt.Sprintf(`case %d:
+			m.%s = m.%s || [];
+			m.%s.push(%s);
+			break;`, f.Number, f.Name, f.Name, f.Name, protoReadCall(f, "r"))
+	}
+
+	return fmt.Sprintf(`case %d:
+			m.%s = %s;
+			break;`, f.Number, f.Name, protoReadCall(f, "r"))
+}
@@ -0,0 +1,117 @@
+package minimal
+
+import (
+	"strings"
+	"testing"
+)
+
+// protoEncodeField/protoDecodeCase must route each field shape to the wire
+// codec its layout requires: zigzag varints for sint32/64, packed encoding
+// for repeated scalars, length-delimited recursion for maps and messages,
+// and raw skip for fields the generator only supports over application/json.
+func TestProtoEncodeDecodeField(t *testing.T) {
+	tests := []struct {
+		name       string
+		field      ModelField
+		wantEncode []string
+		wantDecode []string
+	}{
+		{
+			name:       "sint64 uses zigzag varint",
+			field:      ModelField{Name: "n", Number: 1, WireType: wireVarint, ZigZag: true, Kind: FieldKindInt64},
+			wantEncode: []string{"w.tag(1, 0)", "sint64(m.n)"},
+			wantDecode: []string{"case 1:", "r.sint64()"},
+		},
+		{
+			name:       "fixed64 is written and read as 8 raw unsigned bytes",
+			field:      ModelField{Name: "n", Number: 2, WireType: wireFixed64, Kind: FieldKindInt64, Type: "bigint"},
+			wantEncode: []string{"w.tag(2, 1)", "fixed64(m.n)"},
+			wantDecode: []string{"case 2:", "r.fixed64()"},
+		},
+		{
+			name:       "sfixed64 is written as 8 raw bytes but read back signed",
+			field:      ModelField{Name: "n", Number: 2, WireType: wireFixed64, Signed: true, Kind: FieldKindInt64, Type: "bigint"},
+			wantEncode: []string{"w.tag(2, 1)", "sfixed64(m.n)"},
+			wantDecode: []string{"case 2:", "r.sfixed64()"},
+		},
+		{
+			name:       "double stays on the fixed64 wire type but the float read/write pair",
+			field:      ModelField{Name: "n", Number: 2, WireType: wireFixed64, Kind: FieldKindFloat, Type: "number"},
+			wantEncode: []string{"w.tag(2, 1)", "double(m.n)"},
+			wantDecode: []string{"case 2:", "r.double()"},
+		},
+		{
+			name:       "packed repeated scalar forks a length-delimited sub-writer",
+			field:      ModelField{Name: "ns", Number: 2, WireType: wireVarint, IsRepeated: true, Packed: true, Type: "number"},
+			wantEncode: []string{"w.tag(2, wireLengthDelimited).fork()", "w.ldelim()"},
+			wantDecode: []string{"new Reader(r.bytes())"},
+		},
+		{
+			name:       "non-packable repeated scalar is tagged per element",
+			field:      ModelField{Name: "ss", Number: 3, WireType: wireLengthDelimited, IsRepeated: true, Type: "string"},
+			wantEncode: []string{"w.tag(3, 2).string(v)"},
+			wantDecode: []string{"m.ss.push(r.string())"},
+		},
+		{
+			name:       "nested message recurses into its own encode/decode",
+			field:      ModelField{Name: "child", Number: 4, WireType: wireLengthDelimited, IsMessage: true, Type: "Child"},
+			wantEncode: []string{"bytes(encodeChild(m.child))"},
+			wantDecode: []string{"decodeChild(r.bytes())"},
+		},
+		{
+			name:       "duration is json-only and skipped on the wire",
+			field:      ModelField{Name: "d", Number: 5, Kind: FieldKindDuration},
+			wantEncode: []string{"google.protobuf well-known type fields are only supported over application/json"},
+			wantDecode: []string{"r.skip(wireType)"},
+		},
+	}
+
+	ctx := &APIContext{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := protoEncodeField(ctx, tt.field)
+			for _, want := range tt.wantEncode {
+				if !strings.Contains(encoded, want) {
+					t.Errorf("protoEncodeField(%s) = %q, want it to contain %q", tt.name, encoded, want)
+				}
+			}
+
+			decoded := protoDecodeCase(ctx, tt.field)
+			for _, want := range tt.wantDecode {
+				if !strings.Contains(decoded, want) {
+					t.Errorf("protoDecodeCase(%s) = %q, want it to contain %q", tt.name, decoded, want)
+				}
+			}
+		})
+	}
+}
+
+// A map field must encode as repeated key/value entry messages, reusing the
+// synthetic MapEntry model the parser already registered rather than
+// re-deriving the key/value wire layout.
+func TestProtoEncodeDecodeMapField(t *testing.T) {
+	ctx := &APIContext{
+		modelLookup: map[string]*Model{
+			".testpkg.Outer.ItemsEntry": {Name: "Outer_ItemsEntry", IsMap: true, MapValueType: "string"},
+		},
+	}
+	field := ModelField{
+		Name:          "items",
+		Number:        6,
+		WireType:      wireLengthDelimited,
+		IsMap:         true,
+		Type:          "Outer_ItemsEntry",
+		ProtoTypeName: ".testpkg.Outer.ItemsEntry",
+	}
+
+	encoded := protoEncodeField(ctx, field)
+	if !strings.Contains(encoded, "encodeOuter_ItemsEntry({ key, value: m.items[key] })") {
+		t.Errorf("protoEncodeField(map) = %q, want it to encode each entry via its MapEntry model", encoded)
+	}
+
+	decoded := protoDecodeCase(ctx, field)
+	if !strings.Contains(decoded, "decodeOuter_ItemsEntry(r.bytes())") || !strings.Contains(decoded, "entry.value as string") {
+		t.Errorf("protoDecodeCase(map) = %q, want it to decode via its MapEntry model", decoded)
+	}
+}
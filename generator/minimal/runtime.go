@@ -0,0 +1,680 @@
+package minimal
+
+import (
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// runtimeTemplate is the shared support code every generated *.ts client
+// imports from './twirp'. It has no per-file variation, so it's emitted
+// verbatim alongside each generated client.
+const runtimeTemplate = `
+export type Fetch = (input: RequestInfo, init?: RequestInit) => Promise<Response>;
+
+export const createTwirpRequest = (url: string, body: object, optionsOverride: object = {}): Request => {
+	return new Request(url, Object.assign({
+		method: "POST",
+		headers: {
+			"Content-Type": "application/json",
+			"Accept": "application/json",
+		},
+		body: JSON.stringify(body),
+	}, optionsOverride));
+};
+
+export const createTwirpProtoRequest = (url: string, body: Uint8Array, optionsOverride: object = {}): Request => {
+	return new Request(url, Object.assign({
+		method: "POST",
+		headers: {
+			"Content-Type": "application/protobuf",
+			"Accept": "application/protobuf",
+		},
+		body: body,
+	}, optionsOverride));
+};
+
+// TwirpErrorCode enumerates Twirp's canonical error codes; see
+// https://twitchtv.github.io/twirp/docs/spec_v7.html#error-codes.
+export enum TwirpErrorCode {
+	Canceled = "canceled",
+	Unknown = "unknown",
+	InvalidArgument = "invalid_argument",
+	Malformed = "malformed",
+	DeadlineExceeded = "deadline_exceeded",
+	NotFound = "not_found",
+	BadRoute = "bad_route",
+	AlreadyExists = "already_exists",
+	PermissionDenied = "permission_denied",
+	Unauthenticated = "unauthenticated",
+	ResourceExhausted = "resource_exhausted",
+	FailedPrecondition = "failed_precondition",
+	Aborted = "aborted",
+	OutOfRange = "out_of_range",
+	Unimplemented = "unimplemented",
+	Internal = "internal",
+	Unavailable = "unavailable",
+	DataLoss = "data_loss",
+}
+
+const twirpErrorCodes = new Set<string>(Object.values(TwirpErrorCode));
+
+// toTwirpErrorCode maps a raw "code" string off the wire to TwirpErrorCode,
+// falling back to Unknown for a server sending a code this client doesn't
+// recognize rather than throwing while parsing an error response.
+export const toTwirpErrorCode = (code: string | undefined): TwirpErrorCode => {
+	if (code && twirpErrorCodes.has(code)) {
+		return code as TwirpErrorCode;
+	}
+	return TwirpErrorCode.Unknown;
+};
+
+export class TwirpError extends Error {
+	code: TwirpErrorCode;
+
+	constructor(code: TwirpErrorCode, public msg: string, public meta: { [key: string]: string } = {}) {
+		super(msg);
+		this.code = code;
+	}
+}
+
+export const throwTwirpError = (resp: Response): Promise<never> => {
+	return resp.json().then((err) => {
+		throw new TwirpError(toTwirpErrorCode(err.code), err.msg || resp.statusText, err.meta || {});
+	});
+};
+
+// TwirpContext describes the rpc an interceptor is wrapping, mirroring the
+// context twirp-go/grpc-gateway interceptors receive - enough for logging,
+// auth, and retry middleware to make decisions without parsing the URL.
+export interface TwirpContext {
+	packageName: string;
+	serviceName: string;
+	methodName: string;
+}
+
+export type Next = (ctx: TwirpContext, req: Request) => Promise<Response>;
+
+// Interceptor wraps a single rpc call; calling next(ctx, req) continues the
+// chain (onward to the next interceptor, or the underlying Fetch once the
+// chain is exhausted). An interceptor may pass through a modified Request,
+// retry by calling next again, or short-circuit by returning its own
+// Response without calling next at all.
+export type Interceptor = (ctx: TwirpContext, req: Request, next: Next) => Promise<Response>;
+
+// chainInterceptors composes an ordered list of interceptors around fetch,
+// outermost first, into the single (ctx, req) => Promise<Response> function
+// a generated {Service}Client calls for every rpc.
+export const chainInterceptors = (fetch: Fetch, interceptors: Interceptor[]): ((ctx: TwirpContext, req: Request) => Promise<Response>) => {
+	const terminal: Next = (_ctx, req) => fetch(req);
+
+	return interceptors.reduceRight<Next>((next, interceptor) => {
+		return (ctx, req) => interceptor(ctx, req, next);
+	}, terminal);
+};
+
+const mergeHeaders = (base: HeadersInit | undefined, extra: { [key: string]: string }): Headers => {
+	const headers = new Headers(base);
+	for (const key of Object.keys(extra)) {
+		headers.set(key, extra[key]);
+	}
+	return headers;
+};
+
+// withAuthorization attaches an "Authorization: Bearer <token>" header,
+// calling tokenProvider fresh on every request so a refreshed token is
+// always picked up.
+export const withAuthorization = (tokenProvider: () => string | Promise<string>): Interceptor => {
+	return async (ctx, req, next) => {
+		const token = await tokenProvider();
+		const authed = new Request(req, { headers: mergeHeaders(req.headers, { Authorization: "Bearer " + token }) });
+		return next(ctx, authed);
+	};
+};
+
+const sleep = (ms: number): Promise<void> => new Promise((resolve) => setTimeout(resolve, ms));
+
+// parseRetryAfter reads a Retry-After header (either delay-seconds or an
+// HTTP-date, per RFC 7231 7.1.3) into a millisecond delay.
+const parseRetryAfter = (header: string): number => {
+	const seconds = Number(header);
+	if (!Number.isNaN(seconds)) {
+		return seconds * 1000;
+	}
+
+	const date = Date.parse(header);
+	if (!Number.isNaN(date)) {
+		return Math.max(0, date - Date.now());
+	}
+
+	return 0;
+};
+
+// defaultRetryBackoff is a full-jitter exponential backoff, capped at 30s.
+const defaultRetryBackoff = (attempt: number): number => {
+	const cap = Math.min(1000 * 2 ** attempt, 30000);
+	return Math.random() * cap;
+};
+
+export interface RetryOptions {
+	retries: number;
+	backoff?: (attempt: number) => number;
+	retryOn?: TwirpErrorCode[];
+}
+
+const peekTwirpErrorCode = async (resp: Response): Promise<TwirpErrorCode> => {
+	try {
+		const err = await resp.clone().json();
+		return toTwirpErrorCode(err.code);
+	} catch {
+		return TwirpErrorCode.Unknown;
+	}
+};
+
+// withRetry retries a failed rpc up to opts.retries times, honoring the
+// server's Retry-After header when present and otherwise backing off per
+// opts.backoff (default: full-jitter exponential). Only errors whose code
+// is in opts.retryOn (default: Unavailable, ResourceExhausted, Internal)
+// are retried; req is cloned for each attempt since a Request body can
+// only be read once.
+export const withRetry = (opts: RetryOptions): Interceptor => {
+	const backoff = opts.backoff || defaultRetryBackoff;
+	const retryOn = opts.retryOn || [TwirpErrorCode.Unavailable, TwirpErrorCode.ResourceExhausted, TwirpErrorCode.Internal];
+
+	return async (ctx, req, next) => {
+		for (let attempt = 0; ; attempt++) {
+			const resp = await next(ctx, req.clone());
+			if (resp.ok) {
+				return resp;
+			}
+
+			const code = await peekTwirpErrorCode(resp);
+			if (attempt >= opts.retries || !retryOn.includes(code)) {
+				return resp;
+			}
+
+			const retryAfter = resp.headers.get("Retry-After");
+			await sleep(retryAfter ? parseRetryAfter(retryAfter) : backoff(attempt));
+		}
+	};
+};
+
+// anySignal returns a signal that aborts as soon as any of signals does,
+// standing in for the newer AbortSignal.any() for broader target support.
+const anySignal = (signals: (AbortSignal | undefined)[]): AbortSignal => {
+	const controller = new AbortController();
+	for (const signal of signals) {
+		if (!signal) {
+			continue;
+		}
+		if (signal.aborted) {
+			controller.abort(signal.reason);
+			break;
+		}
+		signal.addEventListener("abort", () => controller.abort(signal.reason), { once: true });
+	}
+	return controller.signal;
+};
+
+// withDeadline aborts the request if it hasn't completed within ms,
+// combining with any AbortSignal the caller already attached to req (e.g.
+// a server-streaming call's own "signal" argument) rather than replacing it.
+export const withDeadline = (ms: number): Interceptor => {
+	return async (ctx, req, next) => {
+		const controller = new AbortController();
+		const timer = setTimeout(() => controller.abort(new Error("twirp: deadline of " + ms + "ms exceeded")), ms);
+
+		try {
+			return await next(ctx, new Request(req, { signal: anySignal([req.signal, controller.signal]) }));
+		} finally {
+			clearTimeout(timer);
+		}
+	};
+};
+
+export interface Logger {
+	log(message: string): void;
+}
+
+// withLogging logs one line per rpc call with its method, status, and
+// duration, or the thrown error if the call never produced a Response.
+export const withLogging = (logger: Logger = console): Interceptor => {
+	return async (ctx, req, next) => {
+		const start = Date.now();
+		try {
+			const resp = await next(ctx, req);
+			logger.log("twirp " + ctx.serviceName + "." + ctx.methodName + " " + resp.status + " " + (Date.now() - start) + "ms");
+			return resp;
+		} catch (err) {
+			logger.log("twirp " + ctx.serviceName + "." + ctx.methodName + " failed after " + (Date.now() - start) + "ms: " + err);
+			throw err;
+		}
+	};
+};
+
+// Twirp streaming frame flags. Each frame on a server-streaming response is
+// a 5-byte header (1 flag byte + 4-byte big-endian payload length) followed
+// by that many bytes of JSON or protobuf payload. A plain message frame's
+// flag byte is 0x00, so only the two special cases need a name.
+const streamFlagLastMessage = 0x01;
+const streamFlagError = 0x80;
+
+// readTwirpStream turns a server-streaming Twirp response into an
+// AsyncIterable, decoding each length-prefixed frame with the caller's
+// decode function and throwing a TwirpError if the server sends an error
+// frame instead of a final message.
+export async function* readTwirpStream<T>(respPromise: Promise<Response>, decode: (payload: Uint8Array) => T): AsyncGenerator<T, void, unknown> {
+	const resp = await respPromise;
+	if (!resp.ok) {
+		await throwTwirpError(resp);
+		return;
+	}
+	if (!resp.body) {
+		throw new Error("twirp streaming response has no body");
+	}
+
+	const reader = resp.body.getReader();
+	let buffer = new Uint8Array(0);
+
+	const fill = async (n: number): Promise<boolean> => {
+		while (buffer.length < n) {
+			const { done, value } = await reader.read();
+			if (done) {
+				return false;
+			}
+			const next = new Uint8Array(buffer.length + value.length);
+			next.set(buffer, 0);
+			next.set(value, buffer.length);
+			buffer = next;
+		}
+		return true;
+	};
+
+	for (;;) {
+		if (!(await fill(5))) {
+			if (buffer.length > 0) {
+				throw new Error("twirp stream ended mid-frame");
+			}
+			return;
+		}
+
+		const flag = buffer[0];
+		const length = new DataView(buffer.buffer, buffer.byteOffset + 1, 4).getUint32(0, false);
+
+		if (!(await fill(5 + length))) {
+			throw new Error("twirp stream ended mid-frame");
+		}
+
+		const payload = buffer.slice(5, 5 + length);
+		buffer = buffer.slice(5 + length);
+
+		if (flag === streamFlagError) {
+			const err = JSON.parse(new TextDecoder().decode(payload));
+			throw new TwirpError(toTwirpErrorCode(err.code), err.msg || "stream error", err.meta || {});
+		}
+
+		yield decode(payload);
+
+		if (flag === streamFlagLastMessage) {
+			return;
+		}
+	}
+}
+
+// bytesToBase64/base64ToBytes implement the proto3 JSON mapping for the
+// bytes scalar type (a standard base64 string), without pulling in a
+// Buffer polyfill for browser targets.
+export const bytesToBase64 = (bytes: Uint8Array): string => {
+	let binary = "";
+	for (let i = 0; i < bytes.length; i++) {
+		binary += String.fromCharCode(bytes[i]);
+	}
+	return btoa(binary);
+};
+
+export const base64ToBytes = (b64: string): Uint8Array => {
+	const binary = atob(b64);
+	const bytes = new Uint8Array(binary.length);
+	for (let i = 0; i < binary.length; i++) {
+		bytes[i] = binary.charCodeAt(i);
+	}
+	return bytes;
+};
+
+// encodeFloat/decodeFloat implement the proto3 JSON mapping for double and
+// float fields, which represent NaN/Infinity/-Infinity as strings since
+// JSON.stringify would otherwise drop them to null.
+export const encodeFloat = (n: number): number | string => {
+	if (Number.isNaN(n)) {
+		return "NaN";
+	}
+	if (n === Infinity) {
+		return "Infinity";
+	}
+	if (n === -Infinity) {
+		return "-Infinity";
+	}
+	return n;
+};
+
+export const decodeFloat = (n: number | string): number => {
+	if (n === "NaN") {
+		return NaN;
+	}
+	if (n === "Infinity") {
+		return Infinity;
+	}
+	if (n === "-Infinity") {
+		return -Infinity;
+	}
+	return n as number;
+};
+
+// Wire type tags, per https://protobuf.dev/programming-guides/encoding/#structure.
+export const wireVarint = 0;
+export const wireFixed64 = 1;
+export const wireLengthDelimited = 2;
+export const wireFixed32 = 5;
+
+// UnknownField preserves a single field this client's descriptor wasn't
+// built against: fieldNumber/wireType are enough to re-emit its tag, and
+// data is its already wire-encoded payload (including, for
+// wireLengthDelimited, its own length prefix), captured verbatim by
+// Reader.captureField so a decode-then-encode round trip doesn't drop it.
+export interface UnknownField {
+	fieldNumber: number;
+	wireType: number;
+	data: Uint8Array;
+}
+
+const textEncoder = new TextEncoder();
+const textDecoder = new TextDecoder();
+
+// Writer is a minimal hand-rolled protobuf wire encoder: varint/zigzag
+// integers, fixed32/fixed64, length-delimited bytes/strings/submessages,
+// and fork()/ldelim() to length-prefix a packed repeated run or a nested
+// message without a second encoding pass.
+export class Writer {
+	private chunks: Uint8Array[] = [];
+	private stack: Uint8Array[][] = [];
+
+	private push(bytes: Uint8Array): Writer {
+		this.chunks.push(bytes);
+		return this;
+	}
+
+	tag(fieldNumber: number, wireType: number): Writer {
+		return this.uint32((fieldNumber << 3) | wireType);
+	}
+
+	uint32(value: number): Writer {
+		const bytes: number[] = [];
+		let v = value >>> 0;
+		while (v > 0x7f) {
+			bytes.push((v & 0x7f) | 0x80);
+			v >>>= 7;
+		}
+		bytes.push(v);
+		return this.push(Uint8Array.from(bytes));
+	}
+
+	int32(value: number): Writer {
+		return this.uint32(value < 0 ? value + 0x100000000 : value);
+	}
+
+	sint32(value: number): Writer {
+		return this.uint32(((value << 1) ^ (value >> 31)) >>> 0);
+	}
+
+	int64(value: bigint): Writer {
+		let v = value < 0n ? value + (1n << 64n) : value;
+		const bytes: number[] = [];
+		while (v > 0x7fn) {
+			bytes.push(Number((v & 0x7fn) | 0x80n));
+			v >>= 7n;
+		}
+		bytes.push(Number(v));
+		return this.push(Uint8Array.from(bytes));
+	}
+
+	sint64(value: bigint): Writer {
+		return this.int64((value << 1n) ^ (value >> 63n));
+	}
+
+	fixed32(value: number): Writer {
+		const buf = new ArrayBuffer(4);
+		new DataView(buf).setUint32(0, value, true);
+		return this.push(new Uint8Array(buf));
+	}
+
+	float(value: number): Writer {
+		const buf = new ArrayBuffer(4);
+		new DataView(buf).setFloat32(0, value, true);
+		return this.push(new Uint8Array(buf));
+	}
+
+	fixed64(value: bigint): Writer {
+		const buf = new ArrayBuffer(8);
+		new DataView(buf).setBigUint64(0, value < 0n ? value + (1n << 64n) : value, true);
+		return this.push(new Uint8Array(buf));
+	}
+
+	// sfixed64 writes the same 8 raw little-endian bytes as fixed64 - the
+	// two's-complement bit pattern is identical, only the Reader side needs
+	// to know to sign-extend it back.
+	sfixed64(value: bigint): Writer {
+		return this.fixed64(value);
+	}
+
+	double(value: number): Writer {
+		const buf = new ArrayBuffer(8);
+		new DataView(buf).setFloat64(0, value, true);
+		return this.push(new Uint8Array(buf));
+	}
+
+	bool(value: boolean): Writer {
+		return this.uint32(value ? 1 : 0);
+	}
+
+	bytes(value: Uint8Array): Writer {
+		this.uint32(value.length);
+		return this.push(value);
+	}
+
+	string(value: string): Writer {
+		return this.bytes(textEncoder.encode(value));
+	}
+
+	// raw appends bytes exactly as given, with no length prefix - for
+	// re-emitting an UnknownField's already wire-encoded payload after its
+	// own tag() call.
+	raw(bytes: Uint8Array): Writer {
+		return this.push(bytes);
+	}
+
+	// fork begins a length-delimited run (a packed repeated field or a
+	// nested message's fields); ldelim closes it and writes its byte
+	// length ahead of the buffered content.
+	fork(): Writer {
+		this.stack.push(this.chunks);
+		this.chunks = [];
+		return this;
+	}
+
+	ldelim(): Writer {
+		const inner = this.chunks;
+		this.chunks = this.stack.pop() || [];
+		return this.bytes(concatBytes(inner));
+	}
+
+	finish(): Uint8Array {
+		return concatBytes(this.chunks);
+	}
+}
+
+const concatBytes = (chunks: Uint8Array[]): Uint8Array => {
+	const len = chunks.reduce((n, c) => n + c.length, 0);
+	const out = new Uint8Array(len);
+	let offset = 0;
+	for (const c of chunks) {
+		out.set(c, offset);
+		offset += c.length;
+	}
+	return out;
+};
+
+// Reader is the Writer's counterpart: a cursor over a Uint8Array that
+// decodes the same varint/zigzag/fixed32/fixed64/length-delimited shapes.
+export class Reader {
+	pos = 0;
+	len: number;
+
+	constructor(private buf: Uint8Array) {
+		this.len = buf.length;
+	}
+
+	tag(): [number, number] {
+		const v = this.uint32();
+		return [v >>> 3, v & 0x7];
+	}
+
+	uint32(): number {
+		let result = 0;
+		let shift = 0;
+		for (;;) {
+			const b = this.buf[this.pos++];
+			result |= (b & 0x7f) << shift;
+			if ((b & 0x80) === 0) {
+				break;
+			}
+			shift += 7;
+		}
+		return result >>> 0;
+	}
+
+	int32(): number {
+		return this.uint32() | 0;
+	}
+
+	sint32(): number {
+		const v = this.uint32();
+		return (v >>> 1) ^ -(v & 1);
+	}
+
+	int64(): bigint {
+		let result = 0n;
+		let shift = 0n;
+		for (;;) {
+			const b = this.buf[this.pos++];
+			result |= BigInt(b & 0x7f) << shift;
+			if ((b & 0x80) === 0) {
+				break;
+			}
+			shift += 7n;
+		}
+		return BigInt.asIntN(64, result);
+	}
+
+	sint64(): bigint {
+		const v = this.int64();
+		return (v >> 1n) ^ -(v & 1n);
+	}
+
+	fixed32(): number {
+		const v = new DataView(this.buf.buffer, this.buf.byteOffset + this.pos, 4).getUint32(0, true);
+		this.pos += 4;
+		return v;
+	}
+
+	float(): number {
+		const v = new DataView(this.buf.buffer, this.buf.byteOffset + this.pos, 4).getFloat32(0, true);
+		this.pos += 4;
+		return v;
+	}
+
+	fixed64(): bigint {
+		const v = new DataView(this.buf.buffer, this.buf.byteOffset + this.pos, 8).getBigUint64(0, true);
+		this.pos += 8;
+		return v;
+	}
+
+	sfixed64(): bigint {
+		return BigInt.asIntN(64, this.fixed64());
+	}
+
+	double(): number {
+		const v = new DataView(this.buf.buffer, this.buf.byteOffset + this.pos, 8).getFloat64(0, true);
+		this.pos += 8;
+		return v;
+	}
+
+	bool(): boolean {
+		return this.uint32() !== 0;
+	}
+
+	bytes(): Uint8Array {
+		const len = this.uint32();
+		const out = this.buf.subarray(this.pos, this.pos + len);
+		this.pos += len;
+		return out;
+	}
+
+	string(): string {
+		return textDecoder.decode(this.bytes());
+	}
+
+	// captureField consumes one field's payload exactly like skip, but
+	// returns the raw bytes consumed instead of discarding them - the
+	// payload only, with no tag, but including its own length prefix for
+	// wireLengthDelimited - so an unrecognized field can be re-emitted
+	// verbatim later via Writer.raw.
+	captureField(wireType: number): Uint8Array {
+		const start = this.pos;
+		switch (wireType) {
+			case wireVarint:
+				this.uint32();
+				break;
+			case wireFixed64:
+				this.pos += 8;
+				break;
+			case wireLengthDelimited:
+				this.bytes();
+				break;
+			case wireFixed32:
+				this.pos += 4;
+				break;
+			default:
+				throw new Error("unsupported wire type " + wireType);
+		}
+		return this.buf.slice(start, this.pos);
+	}
+
+	skip(wireType: number): void {
+		switch (wireType) {
+			case wireVarint:
+				this.uint32();
+				break;
+			case wireFixed64:
+				this.pos += 8;
+				break;
+			case wireLengthDelimited:
+				this.bytes();
+				break;
+			case wireFixed32:
+				this.pos += 4;
+				break;
+			default:
+				throw new Error("unsupported wire type " + wireType);
+		}
+	}
+}
+`
+
+// RuntimeLibrary returns the shared './twirp' support module every
+// generated client file imports from.
+func RuntimeLibrary() *plugin.CodeGeneratorResponse_File {
+	f := &plugin.CodeGeneratorResponse_File{}
+	f.Name = proto.String("twirp.ts")
+	f.Content = proto.String(runtimeTemplate)
+
+	return f
+}
@@ -0,0 +1,105 @@
+package minimal
+
+import (
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// walkEnum registers e as a top-level Enum. protoName is e's
+// fully-qualified proto name (e.g. ".pkg.Outer.Status"); its
+// package-stripped, underscore-joined form (via removePkg) becomes the
+// generated TS enum name, so an enum nested three levels deep renders as
+// e.g. "Outer_Middle_Status".
+func (ctx *APIContext) walkEnum(protoName string, e *descriptor.EnumDescriptorProto) {
+	enum := &Enum{
+		Name: ctx.removePkg(protoName),
+	}
+	for _, ev := range e.GetValue() {
+		enum.Values = append(enum.Values, EnumValue{Name: ev.GetName(), Number: ev.GetNumber()})
+	}
+
+	ctx.Enums = append(ctx.Enums, enum)
+	ctx.enumLookup[protoName] = enum
+	if ctx.fileOwner != nil {
+		ctx.fileOwner[protoName] = ctx.currentFile
+	}
+}
+
+// walkMessage recursively registers a Model for m and everything nested
+// inside it - messages, enums, map entries, and oneofs - at any depth.
+// protoName is m's fully-qualified proto name (e.g. ".pkg.Outer.Inner") and
+// is both the modelLookup key (so field lookups are never ambiguous,
+// regardless of how deep m is nested) and, via removePkg, the source of m's
+// flattened TS name (e.g. "Outer_Inner").
+func (ctx *APIContext) walkMessage(protoName string, m *descriptor.DescriptorProto) {
+	model := &Model{
+		Name: ctx.removePkg(protoName),
+	}
+
+	if m.Options.GetMapEntry() {
+		model.IsMap = true
+	}
+
+	for _, e := range m.GetEnumType() {
+		ctx.walkEnum(protoName+"."+e.GetName(), e)
+	}
+
+	for _, m2 := range m.GetNestedType() {
+		ctx.walkMessage(protoName+"."+m2.GetName(), m2)
+	}
+
+	ctx.AddModel(protoName, model)
+
+	fields := make([]ModelField, len(m.GetField()))
+	for i, f := range m.GetField() {
+		fields[i] = ctx.newField(f)
+
+		if model.IsMap && fields[i].Name == "value" {
+			model.MapValueType = fields[i].Type
+			if f.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+				model.MapValueTypePrimitive = true
+			}
+		}
+	}
+
+	ctx.groupOneofs(model, m, fields)
+	model.Fields = fields
+}
+
+// groupOneofs inspects m's oneof_decls and, for each one with more than one
+// real member, builds an OneofGroup and points its members' OneofField at
+// it. A oneof with a single member is proto3's "optional" keyword in
+// disguise (a synthetic one-field oneof, Proto3Optional); those stay plain
+// optional fields since there's nothing to discriminate between.
+func (ctx *APIContext) groupOneofs(model *Model, m *descriptor.DescriptorProto, fields []ModelField) {
+	memberCount := make(map[int32]int)
+	for _, f := range m.GetField() {
+		if f.OneofIndex != nil && !f.GetProto3Optional() {
+			memberCount[f.GetOneofIndex()]++
+		}
+	}
+
+	groups := make(map[int32]*OneofGroup)
+	for i, f := range m.GetField() {
+		if f.OneofIndex == nil || f.GetProto3Optional() || memberCount[f.GetOneofIndex()] < 2 {
+			continue
+		}
+
+		idx := f.GetOneofIndex()
+		group, ok := groups[idx]
+		if !ok {
+			fieldName := camelCase(m.GetOneofDecl()[idx].GetName())
+			group = &OneofGroup{
+				Name:      model.Name + "_" + strings.ToUpper(fieldName[0:1]) + fieldName[1:],
+				FieldName: fieldName,
+			}
+			groups[idx] = group
+			model.Oneofs = append(model.Oneofs, group)
+		}
+
+		fields[i].OneofField = group.FieldName
+		fields[i].OneofCase = f.GetName()
+		group.Members = append(group.Members, fields[i])
+	}
+}
@@ -0,0 +1,201 @@
+package minimal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func newTestCtx(pkg string) *APIContext {
+	ctx := NewAPIContext("v7")
+	ctx.Package = pkg
+	return &ctx
+}
+
+func strField(name string, number int32) *descriptor.FieldDescriptorProto {
+	return &descriptor.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Label:  descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:   descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+	}
+}
+
+func msgField(name string, number int32, typeName string) *descriptor.FieldDescriptorProto {
+	return &descriptor.FieldDescriptorProto{
+		Name:     proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+		TypeName: proto.String(typeName),
+	}
+}
+
+// A message nested three levels deep should still be found under its
+// fully-qualified name, with a flattened TS name that chains every
+// ancestor rather than just its immediate parent.
+func TestWalkMessageDeepNesting(t *testing.T) {
+	ctx := newTestCtx("testpkg")
+
+	leaf := &descriptor.DescriptorProto{
+		Name:  proto.String("Leaf"),
+		Field: []*descriptor.FieldDescriptorProto{strField("name", 1)},
+	}
+	middle := &descriptor.DescriptorProto{
+		Name:       proto.String("Middle"),
+		NestedType: []*descriptor.DescriptorProto{leaf},
+	}
+	outer := &descriptor.DescriptorProto{
+		Name:       proto.String("Outer"),
+		NestedType: []*descriptor.DescriptorProto{middle},
+	}
+
+	ctx.walkMessage(".testpkg.Outer", outer)
+
+	model, ok := ctx.modelLookup[".testpkg.Outer.Middle.Leaf"]
+	if !ok {
+		t.Fatalf("expected modelLookup to contain the doubly-nested Leaf message")
+	}
+	if model.Name != "Outer_Middle_Leaf" {
+		t.Errorf("got TS name %q, want Outer_Middle_Leaf", model.Name)
+	}
+}
+
+// Two top-level messages that reference each other must both resolve via
+// modelLookup regardless of which one is walked first.
+func TestWalkMessageMutualRecursion(t *testing.T) {
+	ctx := newTestCtx("testpkg")
+
+	a := &descriptor.DescriptorProto{
+		Name:  proto.String("A"),
+		Field: []*descriptor.FieldDescriptorProto{msgField("b", 1, ".testpkg.B")},
+	}
+	b := &descriptor.DescriptorProto{
+		Name:  proto.String("B"),
+		Field: []*descriptor.FieldDescriptorProto{msgField("a", 1, ".testpkg.A")},
+	}
+
+	ctx.walkMessage(".testpkg.A", a)
+	ctx.walkMessage(".testpkg.B", b)
+
+	modelA, ok := ctx.modelLookup[".testpkg.A"]
+	if !ok || len(modelA.Fields) != 1 || modelA.Fields[0].ProtoTypeName != ".testpkg.B" {
+		t.Fatalf("model A should have a field referencing B by FQN, got %+v", modelA)
+	}
+
+	modelB, ok := ctx.modelLookup[".testpkg.B"]
+	if !ok || len(modelB.Fields) != 1 || modelB.Fields[0].ProtoTypeName != ".testpkg.A" {
+		t.Fatalf("model B should have a field referencing A by FQN, got %+v", modelB)
+	}
+}
+
+// A map<string, Inner> field nested inside Outer (itself alongside Inner,
+// a sibling nested type) must have its synthetic MapEntry resolved so the
+// map field and its entry's value type are correctly linked.
+func TestWalkMessageNestedMapOfMessages(t *testing.T) {
+	ctx := newTestCtx("testpkg")
+
+	inner := &descriptor.DescriptorProto{
+		Name:  proto.String("Inner"),
+		Field: []*descriptor.FieldDescriptorProto{strField("x", 1)},
+	}
+	entry := &descriptor.DescriptorProto{
+		Name: proto.String("ItemsEntry"),
+		Field: []*descriptor.FieldDescriptorProto{
+			strField("key", 1),
+			msgField("value", 2, ".testpkg.Outer.Inner"),
+		},
+		Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+	outer := &descriptor.DescriptorProto{
+		Name:       proto.String("Outer"),
+		NestedType: []*descriptor.DescriptorProto{inner, entry},
+		Field: []*descriptor.FieldDescriptorProto{
+			{
+				Name:     proto.String("items"),
+				Number:   proto.Int32(1),
+				Label:    descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".testpkg.Outer.ItemsEntry"),
+			},
+		},
+	}
+
+	ctx.walkMessage(".testpkg.Outer", outer)
+
+	entryModel, ok := ctx.modelLookup[".testpkg.Outer.ItemsEntry"]
+	if !ok || !entryModel.IsMap {
+		t.Fatalf("expected ItemsEntry to be registered as a map model")
+	}
+	if entryModel.MapValueType != "Outer_Inner" {
+		t.Errorf("map value type = %q, want Outer_Inner", entryModel.MapValueType)
+	}
+
+	outerModel := ctx.modelLookup[".testpkg.Outer"]
+	if len(outerModel.Fields) != 1 || !outerModel.Fields[0].IsMap {
+		t.Fatalf("items field should be detected as a map, got %+v", outerModel.Fields)
+	}
+}
+
+// A oneof with a message member that refers back to its own parent message
+// must become a discriminated union, and the nested message's self
+// reference must still resolve correctly.
+func TestWalkMessageOneofReferencingParent(t *testing.T) {
+	ctx := newTestCtx("testpkg")
+
+	other := &descriptor.DescriptorProto{
+		Name:  proto.String("Other"),
+		Field: []*descriptor.FieldDescriptorProto{msgField("parent", 1, ".testpkg.Msg")},
+	}
+
+	oneofIndex := int32(0)
+	msg := &descriptor.DescriptorProto{
+		Name:       proto.String("Msg"),
+		NestedType: []*descriptor.DescriptorProto{other},
+		OneofDecl:  []*descriptor.OneofDescriptorProto{{Name: proto.String("kind")}},
+		Field: []*descriptor.FieldDescriptorProto{
+			{
+				Name:       proto.String("text"),
+				Number:     proto.Int32(1),
+				Label:      descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:       descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+				OneofIndex: &oneofIndex,
+			},
+			{
+				Name:       proto.String("other"),
+				Number:     proto.Int32(2),
+				Label:      descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:       descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName:   proto.String(".testpkg.Msg.Other"),
+				OneofIndex: &oneofIndex,
+			},
+		},
+	}
+
+	ctx.walkMessage(".testpkg.Msg", msg)
+
+	model := ctx.modelLookup[".testpkg.Msg"]
+	if len(model.Oneofs) != 1 {
+		t.Fatalf("expected one oneof group, got %d", len(model.Oneofs))
+	}
+
+	group := model.Oneofs[0]
+	if group.FieldName != "kind" || group.Name != "Msg_Kind" {
+		t.Errorf("unexpected oneof group naming: %+v", group)
+	}
+	if len(group.Members) != 2 || group.Members[1].ProtoTypeName != ".testpkg.Msg.Other" {
+		t.Fatalf("expected 2 members with the second referencing the nested Other message, got %+v", group.Members)
+	}
+
+	for _, f := range model.Fields {
+		if f.Name == "text" && f.OneofField != "kind" {
+			t.Errorf("text field should be tagged with its enclosing oneof, got %q", f.OneofField)
+		}
+	}
+
+	otherModel, ok := ctx.modelLookup[".testpkg.Msg.Other"]
+	if !ok || len(otherModel.Fields) != 1 || otherModel.Fields[0].ProtoTypeName != ".testpkg.Msg" {
+		t.Fatalf("Other.parent should reference back to Msg, got %+v", otherModel)
+	}
+}
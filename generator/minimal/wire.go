@@ -0,0 +1,94 @@
+package minimal
+
+import "github.com/golang/protobuf/protoc-gen-go/descriptor"
+
+// Wire type tags as defined by the protobuf encoding spec
+// (https://protobuf.dev/programming-guides/encoding/#structure). These are
+// the literal values packed into the low 3 bits of a field's tag byte, so
+// the generated TS reads/writes them directly rather than through a layer
+// of indirection.
+const (
+	wireVarint          = 0
+	wireFixed64         = 1
+	wireLengthDelimited = 2
+	wireFixed32         = 5
+)
+
+// wireInfo is everything a field needs to encode/decode itself against the
+// application/protobuf wire format.
+type wireInfo struct {
+	WireType int32
+	ZigZag   bool // sint32/sint64 use zigzag varint encoding instead of plain varint
+	Packable bool // numeric scalar eligible for proto3's default packed repeated encoding
+	Signed   bool // sfixed64 needs a signed read back out of its 8 raw bytes; fixed64/double don't
+}
+
+// fieldWireInfo classifies a field by its proto type so the generator can
+// emit the right Writer/Reader calls. Unlike FieldKind (the TS/JSON type
+// pair), this only cares about wire representation, so e.g. int32 and
+// sint32 - identical in TS - differ here by ZigZag.
+func fieldWireInfo(f *descriptor.FieldDescriptorProto) wireInfo {
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_BOOL,
+		descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return wireInfo{WireType: wireVarint, Packable: true}
+	case descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return wireInfo{WireType: wireVarint, ZigZag: true, Packable: true}
+	case descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return wireInfo{WireType: wireFixed64, Packable: true}
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return wireInfo{WireType: wireFixed64, Packable: true, Signed: true}
+	case descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return wireInfo{WireType: wireFixed32, Packable: true}
+	default:
+		// TYPE_STRING, TYPE_BYTES, TYPE_MESSAGE, and map entries are all
+		// length-delimited and never packed.
+		return wireInfo{WireType: wireLengthDelimited}
+	}
+}
+
+// wireWriteMethod returns the Writer method name used to encode a single
+// scalar value of this field's type, and wireReadMethod the matching Reader
+// method name used to decode it. Message, map, and enum fields are handled
+// separately by protoEncodeField/protoDecodeCase since they need to recurse
+// into encode{Model}/decode{Model} or a {Enum}Number/{Enum}FromNumber table.
+func wireWriteMethod(f ModelField) string {
+	switch {
+	case f.Kind == FieldKindBytes:
+		return "bytes"
+	case f.WireType == wireLengthDelimited:
+		return "string"
+	case f.ZigZag && f.Kind == FieldKindInt64:
+		return "sint64"
+	case f.ZigZag:
+		return "sint32"
+	case f.WireType == wireFixed64 && f.Kind == FieldKindFloat:
+		return "double"
+	case f.WireType == wireFixed64 && f.Signed:
+		return "sfixed64"
+	case f.WireType == wireFixed64:
+		return "fixed64"
+	case f.Kind == FieldKindInt64:
+		return "int64"
+	case f.WireType == wireFixed32 && f.Kind == FieldKindFloat:
+		return "float"
+	case f.WireType == wireFixed32:
+		return "fixed32"
+	case f.Type == "boolean" || f.Type == "boolean[]":
+		return "bool"
+	default:
+		return "int32"
+	}
+}
+
+func wireReadMethod(f ModelField) string {
+	return wireWriteMethod(f)
+}
@@ -0,0 +1,33 @@
+package minimal
+
+import "testing"
+
+// wireWriteMethod must check WireType before Kind == FieldKindInt64: FIXED64
+// and SFIXED64 fields are also classified as FieldKindInt64 (see fieldKind),
+// so a naive Kind-first check would tag them wireFixed64 but then encode them
+// with the variable-length int64 varint method, desyncing any spec-compliant
+// decoder expecting exactly 8 raw bytes.
+func TestWireWriteMethodFixed64Routing(t *testing.T) {
+	tests := []struct {
+		name string
+		f    ModelField
+		want string
+	}{
+		{"int64", ModelField{Kind: FieldKindInt64, WireType: wireVarint, Type: "bigint"}, "int64"},
+		{"fixed64", ModelField{Kind: FieldKindInt64, WireType: wireFixed64, Type: "bigint"}, "fixed64"},
+		{"sfixed64", ModelField{Kind: FieldKindInt64, WireType: wireFixed64, Signed: true, Type: "bigint"}, "sfixed64"},
+		{"double", ModelField{Kind: FieldKindFloat, WireType: wireFixed64, Type: "number"}, "double"},
+		{"sint64", ModelField{Kind: FieldKindInt64, WireType: wireVarint, ZigZag: true, Type: "bigint"}, "sint64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wireWriteMethod(tt.f); got != tt.want {
+				t.Errorf("wireWriteMethod(%+v) = %q, want %q", tt.f, got, tt.want)
+			}
+			if got := wireReadMethod(tt.f); got != tt.want {
+				t.Errorf("wireReadMethod(%+v) = %q, want %q", tt.f, got, tt.want)
+			}
+		})
+	}
+}
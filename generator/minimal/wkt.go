@@ -0,0 +1,67 @@
+package minimal
+
+// FieldKind captures the canonical proto3 JSON mapping
+// (https://protobuf.dev/programming-guides/proto3/#json) for fields whose
+// JSON representation differs from their TypeScript representation. Most
+// scalar fields are FieldKindDefault, where TS and JSON agree on a plain
+// passthrough value; everything jsonpb/protojson special-cases gets its own
+// kind so the template can pick the right marshal/unmarshal snippet.
+type FieldKind int
+
+const (
+	FieldKindDefault FieldKind = iota
+	FieldKindInt64             // int64/uint64/fixed64/sfixed64/sint64: TS bigint, JSON decimal string
+	FieldKindFloat             // double/float: TS number, JSON number or "NaN"/"Infinity"/"-Infinity"
+	FieldKindBytes             // bytes: TS Uint8Array, JSON base64 string
+	FieldKindEnum              // enum: TS enum member, JSON name string
+	FieldKindDuration          // google.protobuf.Duration: TS/JSON "<seconds>s" string
+	FieldKindFieldMask         // google.protobuf.FieldMask: TS string[], JSON comma-joined string
+	FieldKindStruct            // google.protobuf.Struct: TS/JSON index signature object
+	FieldKindValue             // google.protobuf.Value: TS/JSON any
+	FieldKindListValue         // google.protobuf.ListValue: TS/JSON any[]
+	FieldKindAny               // google.protobuf.Any: TS/JSON {"@type": string, ...} passthrough
+	FieldKindWrapper           // google.protobuf.{String,Int32,...}Value: TS/JSON bare nullable scalar
+	FieldKindEmpty             // google.protobuf.Empty: TS/JSON {}
+)
+
+// wktType describes the TS/JSON type pair and FieldKind a well-known type
+// collapses to when used as a field type. Message fields of these types are
+// not emitted as generated Model interfaces; they're inlined as scalars.
+type wktType struct {
+	TSType   string
+	JSONType string
+	Kind     FieldKind
+}
+
+// wellKnownTypes maps the fully-qualified proto name (as returned by
+// FieldDescriptorProto.GetTypeName) of a google.protobuf well-known type to
+// its proto3 JSON scalar/object representation. google.protobuf.Timestamp is
+// handled separately in protoToTSType since it predates this table.
+var wellKnownTypes = map[string]wktType{
+	".google.protobuf.StringValue": {"string", "string", FieldKindWrapper},
+	".google.protobuf.BoolValue":   {"boolean", "boolean", FieldKindWrapper},
+	".google.protobuf.Int32Value":  {"number", "number", FieldKindWrapper},
+	".google.protobuf.UInt32Value": {"number", "number", FieldKindWrapper},
+	".google.protobuf.FloatValue":  {"number", "number", FieldKindWrapper},
+	".google.protobuf.DoubleValue": {"number", "number", FieldKindWrapper},
+	// Int64Value/UInt64Value reuse FieldKindInt64 rather than FieldKindWrapper
+	// since they need the same bigint<->decimal-string conversion as a plain
+	// int64 field, not a no-op passthrough.
+	".google.protobuf.Int64Value":  {"bigint", "string", FieldKindInt64},
+	".google.protobuf.UInt64Value": {"bigint", "string", FieldKindInt64},
+	".google.protobuf.Duration":    {"string", "string", FieldKindDuration},
+	".google.protobuf.FieldMask":   {"string[]", "string", FieldKindFieldMask},
+	".google.protobuf.Struct":      {"{ [key: string]: any }", "{ [key: string]: any }", FieldKindStruct},
+	".google.protobuf.Value":       {"any", "any", FieldKindValue},
+	".google.protobuf.ListValue":   {"any[]", "any[]", FieldKindListValue},
+	".google.protobuf.Any":         {"{ [key: string]: any }", "{ [key: string]: any }", FieldKindAny},
+	".google.protobuf.Empty":       {"{}", "{}", FieldKindEmpty},
+}
+
+// isWellKnownScalar reports whether typeName is a google.protobuf well-known
+// type that collapses to a JSON scalar/object instead of a generated Model
+// interface, so callers don't need to look it up in modelLookup.
+func isWellKnownScalar(typeName string) bool {
+	_, ok := wellKnownTypes[typeName]
+	return ok
+}